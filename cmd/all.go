@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"github.com/xeger/pipeclean/cmd/ui"
+	"github.com/xeger/pipeclean/filtering"
+	"github.com/xeger/pipeclean/format/csv"
 	"github.com/xeger/pipeclean/nlp"
 	"github.com/xeger/pipeclean/scrubbing"
 )
@@ -19,10 +21,16 @@ var (
 	confidenceFlag  float64
 	configFlag      string
 	contextFlag     []string
+	delimiterFlag   string = ","
+	headerFlag      bool   = true
 	maskFlag        bool
 	modeFlag        string = "mysql"
+	numberFlag      bool
 	parallelismFlag int
+	quoteFlag       string = `"`
 	saltFlag        string
+	strictFlag      string
+	tableFlag       string
 )
 
 type ModelConfig struct {
@@ -72,6 +80,8 @@ type Config struct {
 	Learning map[string]ModelConfig
 	// Scrubbing describes how to clean up data.
 	Scrubbing *scrubbing.Policy
+	// Filtering describes which rows to omit from output (optional).
+	Filtering *filtering.Policy
 }
 
 func DefaultConfig() *Config {
@@ -98,6 +108,14 @@ func NewConfigFile(filename string) (*Config, error) {
 func (cfg *Config) Validate(models map[string]nlp.Model) []error {
 	var errs []error
 
+	if keyErrors := cfg.Scrubbing.LoadKeys(); keyErrors != nil {
+		h := ui.Fatalf("Cannot load keys.")
+		for _, e := range keyErrors {
+			h.Hint(e.Error())
+		}
+		errs = append(errs, keyErrors...)
+	}
+
 	if scrubbingErrors := cfg.Scrubbing.Validate(models); scrubbingErrors != nil {
 		h := ui.Fatalf("Invalid scrubbing policy.")
 		for _, e := range scrubbingErrors {
@@ -106,6 +124,16 @@ func (cfg *Config) Validate(models map[string]nlp.Model) []error {
 		errs = append(errs, scrubbingErrors...)
 	}
 
+	if cfg.Filtering != nil {
+		if filteringErrors := cfg.Filtering.Validate(); filteringErrors != nil {
+			h := ui.Fatalf("Invalid filtering policy.")
+			for _, e := range filteringErrors {
+				h.Hint(e.Error())
+			}
+			errs = append(errs, filteringErrors...)
+		}
+	}
+
 	for name, defn := range cfg.Learning {
 		if err := defn.Validate(); err != nil {
 			errs = append(errs, err)
@@ -182,3 +210,27 @@ func saveModels(models map[string]nlp.Model, path string) error {
 
 	return nil
 }
+
+// Parses a single-character CLI flag value, allowing `\t` or "tab" to stand
+// for a tab character.
+func charFlag(name, value string) byte {
+	switch value {
+	case `\t`, "tab":
+		return '\t'
+	}
+	if len(value) != 1 {
+		ui.Fatalf("Invalid --%s %q", name, value).Hint("must be a single ASCII character, or \"tab\"")
+		ui.Exit('-')
+	}
+	return value[0]
+}
+
+// Creates a CSV context from CLI flags.
+func newCsvContext() *csv.Context {
+	ctx := csv.NewContext()
+	ctx.Delimiter = charFlag("delimiter", delimiterFlag)
+	ctx.Quote = charFlag("quote", quoteFlag)
+	ctx.Header = headerFlag
+	ctx.TableName = tableFlag
+	return ctx
+}
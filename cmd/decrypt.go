@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xeger/pipeclean/cmd/ui"
+	"github.com/xeger/pipeclean/fpe"
+)
+
+// Used for flags.
+var (
+	decryptCmd = &cobra.Command{
+		Use:   "decrypt",
+		Short: "Decrypt",
+		Long: `Parses values from stdin, one per line, that were scrubbed with encrypt(keyName).
+Prints the original values.`,
+		Run: decrypt,
+	}
+)
+
+func init() {
+	decryptCmd.PersistentFlags().StringVarP(&configFlag, "config", "c", "", "configuration file (JSON)")
+	decryptCmd.MarkPersistentFlagRequired("config")
+	decryptCmd.PersistentFlags().BoolVarP(&numberFlag, "number", "n", false, "values are numbers (e.g. from numeric SQL columns)")
+}
+
+func decrypt(cmd *cobra.Command, args []string) {
+	var keyName string
+	if len(args) == 1 {
+		keyName = args[0]
+	} else {
+		ui.Fatalf("Usage: pipeclean decrypt -c <configFile> <keyName>")
+		ui.Exit('-')
+	}
+
+	cfg, err := NewConfigFile(configFlag)
+	if err != nil {
+		ui.Fatal(err)
+		ui.Exit('>')
+	}
+	source, ok := cfg.Scrubbing.Keys[keyName]
+	if !ok {
+		ui.Fatalf("Unrecognized key %q", keyName).Hint("keys are defined in the scrubbing.keys section of the configuration")
+		ui.Exit('>')
+	}
+	key, err := source.Load()
+	if err != nil {
+		ui.Fatalf("Cannot load key %q: %s", keyName, err)
+		ui.Exit('>')
+	}
+	text, err := fpe.NewText(key)
+	if err != nil {
+		ui.Fatalf("Invalid key %q: %s", keyName, err)
+		ui.Exit('>')
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			break
+		}
+		value := strings.TrimRight(line, "\r\n")
+		if numberFlag {
+			fmt.Println(text.DecryptNumber(value))
+		} else {
+			fmt.Println(text.Decrypt(value))
+		}
+	}
+}
@@ -6,7 +6,11 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/xeger/pipeclean/cmd/ui"
+	"github.com/xeger/pipeclean/format/csv"
+	scrubjson "github.com/xeger/pipeclean/format/json"
 	"github.com/xeger/pipeclean/format/mysql"
+	"github.com/xeger/pipeclean/format/postgres"
+	"github.com/xeger/pipeclean/format/sqlite"
 )
 
 // Used for flags.
@@ -30,10 +34,16 @@ func extract(cmd *cobra.Command, args []string) {
 	}
 
 	switch modeFlag {
-	case "json":
+	case "csv":
+		extractCsv(args)
+	case "json", "ndjson":
 		extractJson(args)
 	case "mysql":
 		extractMysql(args)
+	case "postgres":
+		extractPostgres(args)
+	case "sqlite":
+		extractSqlite(args)
 	default:
 		// should never happen (cobra should validate)
 		panic("unknown mode: " + modeFlag)
@@ -41,7 +51,7 @@ func extract(cmd *cobra.Command, args []string) {
 }
 
 func extractJson(names []string) {
-	ui.ExitNotImplemented("extract json")
+	scrubjson.Extract(names, os.Stdin, os.Stdout)
 }
 
 func extractMysql(names []string) {
@@ -57,3 +67,35 @@ func extractMysql(names []string) {
 
 	mysql.Extract(ctx, names, os.Stdin, os.Stdout)
 }
+
+func extractPostgres(names []string) {
+	ctx := postgres.NewContext()
+	for _, file := range contextFlag {
+		sql, err := ioutil.ReadFile(file)
+		if err != nil {
+			ui.Fatal(err)
+			ui.Exit('>')
+		}
+		ctx.Scan(string(sql))
+	}
+
+	postgres.Extract(ctx, names, os.Stdin, os.Stdout)
+}
+
+func extractSqlite(names []string) {
+	ctx := sqlite.NewContext()
+	for _, file := range contextFlag {
+		sql, err := ioutil.ReadFile(file)
+		if err != nil {
+			ui.Fatal(err)
+			ui.Exit('>')
+		}
+		ctx.Scan(string(sql))
+	}
+
+	sqlite.Extract(ctx, names, os.Stdin, os.Stdout)
+}
+
+func extractCsv(names []string) {
+	csv.Extract(newCsvContext(), names, os.Stdin, os.Stdout)
+}
@@ -2,13 +2,18 @@ package cmd
 
 import (
 	"bufio"
+	"io"
 	"io/ioutil"
 	"os"
 	"runtime"
 
 	"github.com/spf13/cobra"
 	"github.com/xeger/pipeclean/cmd/ui"
+	"github.com/xeger/pipeclean/format/csv"
+	scrubjson "github.com/xeger/pipeclean/format/json"
 	"github.com/xeger/pipeclean/format/mysql"
+	"github.com/xeger/pipeclean/format/postgres"
+	"github.com/xeger/pipeclean/format/sqlite"
 	"github.com/xeger/pipeclean/nlp"
 	"github.com/xeger/pipeclean/scrubbing"
 )
@@ -72,10 +77,18 @@ func learn(cmd *cobra.Command, args []string) {
 	// not exist yet!
 
 	switch modeFlag {
+	case "csv":
+		learnCsv(models, cfg.Scrubbing)
 	case "json":
 		learnJson(models, cfg.Scrubbing)
 	case "mysql":
 		learnMysql(models, cfg.Scrubbing)
+	case "ndjson":
+		learnNdjson(models, cfg.Scrubbing)
+	case "postgres":
+		learnPostgres(models, cfg.Scrubbing)
+	case "sqlite":
+		learnSqlite(models, cfg.Scrubbing)
 	default:
 		ui.ExitBug("unknown mode: " + modeFlag)
 	}
@@ -84,7 +97,28 @@ func learn(cmd *cobra.Command, args []string) {
 }
 
 func learnJson(models map[string]nlp.Model, pol *scrubbing.Policy) {
-	ui.ExitNotImplemented("learn json")
+	scrubjson.Learn(models, pol, os.Stdin)
+}
+
+func learnNdjson(models map[string]nlp.Model, pol *scrubbing.Policy) {
+	// Models are not safe for concurrent training; use a single learner.
+	in := make(chan string)
+	done := make(chan bool)
+	go func() {
+		scrubjson.LearnChan(models, pol, in)
+		done <- true
+	}()
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			break
+		}
+		in <- line
+	}
+	close(in)
+	<-done
 }
 
 func learnMysql(models map[string]nlp.Model, pol *scrubbing.Policy) {
@@ -112,15 +146,107 @@ func learnMysql(models map[string]nlp.Model, pol *scrubbing.Policy) {
 		}
 	}
 
-	reader := bufio.NewReader(os.Stdin)
+	reader := mysql.NewReader(ctx, os.Stdin)
 	l := 0
 	for {
-		line, err := reader.ReadString('\n')
+		stmt, err := reader.ReadStatement()
 		if err != nil {
 			break
 		}
 
-		in[l] <- line
+		in[l] <- stmt.Text
 	}
 	done()
 }
+
+func learnPostgres(models map[string]nlp.Model, pol *scrubbing.Policy) {
+	// Scan any context provided
+	ctx := postgres.NewContext()
+	for _, file := range contextFlag {
+		sql, err := ioutil.ReadFile(file)
+		if err != nil {
+			ui.Fatal(err)
+			ui.Exit('>')
+		}
+		ctx.Scan(string(sql))
+	}
+
+	// Models are not safe for concurrent training; use a single learner.
+	in := make(chan postgres.Line)
+	done := make(chan bool)
+	go func() {
+		postgres.LearnChan(models, pol, in)
+		done <- true
+	}()
+
+	reader := postgres.NewReader(ctx, os.Stdin)
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			break
+		}
+		in <- line
+	}
+	close(in)
+	<-done
+}
+
+func learnSqlite(models map[string]nlp.Model, pol *scrubbing.Policy) {
+	// Scan any context provided
+	ctx := sqlite.NewContext()
+	for _, file := range contextFlag {
+		sql, err := ioutil.ReadFile(file)
+		if err != nil {
+			ui.Fatal(err)
+			ui.Exit('>')
+		}
+		ctx.Scan(string(sql))
+	}
+
+	// Models are not safe for concurrent training; use a single learner.
+	in := make(chan string)
+	done := make(chan bool)
+	go func() {
+		sqlite.LearnChan(ctx, models, pol, in)
+		done <- true
+	}()
+
+	reader := sqlite.NewReader(ctx, os.Stdin)
+	for {
+		stmt, err := reader.ReadStatement()
+		if err != nil {
+			break
+		}
+		in <- stmt
+	}
+	close(in)
+	<-done
+}
+
+func learnCsv(models map[string]nlp.Model, pol *scrubbing.Policy) {
+	ctx := newCsvContext()
+	reader := csv.NewReader(ctx, os.Stdin)
+	if ctx.Header {
+		if _, err := reader.ReadHeader(); err != nil {
+			return
+		}
+	}
+
+	// Models are not safe for concurrent training; use a single learner.
+	in := make(chan string)
+	done := make(chan bool)
+	go func() {
+		csv.LearnChan(ctx, models, pol, in)
+		done <- true
+	}()
+
+	for {
+		record, err := reader.ReadRecord()
+		if err != nil {
+			break
+		}
+		in <- record
+	}
+	close(in)
+	<-done
+}
@@ -11,14 +11,19 @@ var (
 		Short:     "PipeClean",
 		Long:      `PipeClean Streaming Data Sanitizer.`,
 		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
-		ValidArgs: []string{"json", "mysql"},
+		ValidArgs: []string{"csv", "json", "mysql", "ndjson", "postgres", "sqlite"},
 	}
 )
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&modeFlag, "mode", "m", modeFlag, "data format")
 	rootCmd.PersistentFlags().BoolVarP(&ui.IsVerbose, "verbose", "v", false, "print extra debug output")
+	rootCmd.PersistentFlags().StringVar(&delimiterFlag, "delimiter", delimiterFlag, "field delimiter for csv mode (or \"tab\")")
+	rootCmd.PersistentFlags().BoolVar(&headerFlag, "header", headerFlag, "whether the first record of csv input names its columns")
+	rootCmd.PersistentFlags().StringVar(&quoteFlag, "quote", quoteFlag, "quote character for csv mode")
+	rootCmd.PersistentFlags().StringVar(&tableFlag, "table", tableFlag, "table name to prefix csv field names (e.g. users.email)")
 	rootCmd.MarkFlagRequired("mode")
+	rootCmd.AddCommand(decryptCmd)
 	rootCmd.AddCommand(extractCmd)
 	rootCmd.AddCommand(generateCmd)
 	rootCmd.AddCommand(learnCmd)
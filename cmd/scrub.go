@@ -2,15 +2,23 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"runtime"
 
 	"github.com/spf13/cobra"
 	"github.com/xeger/pipeclean/cmd/ui"
+	"github.com/xeger/pipeclean/filtering"
+	"github.com/xeger/pipeclean/format/csv"
 	scrubjson "github.com/xeger/pipeclean/format/json"
 	"github.com/xeger/pipeclean/format/mysql"
+	"github.com/xeger/pipeclean/format/postgres"
+	"github.com/xeger/pipeclean/format/sqlite"
 	"github.com/xeger/pipeclean/nlp"
 	"github.com/xeger/pipeclean/scrubbing"
 )
@@ -32,6 +40,8 @@ func init() {
 	scrubCmd.PersistentFlags().StringSliceVarP(&contextFlag, "context", "x", []string{}, "extra files to parse for improved accuracy")
 	scrubCmd.PersistentFlags().BoolVarP(&maskFlag, "mask", "k", false, "visually verify completeness")
 	scrubCmd.PersistentFlags().StringVarP(&saltFlag, "salt", "s", "", "PRNG seed static diversifier")
+	scrubCmd.PersistentFlags().StringVar(&strictFlag, "strict", "", "handling of unparseable mysql input (or postgres INSERTs): abort or redact")
+	scrubCmd.PersistentFlags().Lookup("strict").NoOptDefVal = "abort"
 }
 
 func scrub(cmd *cobra.Command, args []string) {
@@ -54,16 +64,39 @@ func scrub(cmd *cobra.Command, args []string) {
 	if errs := cfg.Validate(models); errs != nil {
 		ui.Exit('>') // cfg calls ui on its own
 	}
+	if cfg.Filtering != nil && modeFlag != "mysql" {
+		ui.Warnf("Filtering is not supported in %s mode; all rows will be kept", modeFlag)
+	}
+	if len(cfg.Scrubbing.Tables) > 0 && modeFlag != "mysql" {
+		ui.Warnf("Table rules are not supported in %s mode; all tables will be scrubbed", modeFlag)
+	}
+	if err := cfg.Scrubbing.OpenMappings(); err != nil {
+		ui.Fatalf("Cannot open mapping store: %s", err).Hint("check scrubbing.mappings")
+		ui.Exit('>')
+	}
 
 	switch modeFlag {
+	case "csv":
+		scrubCsv(models, cfg.Scrubbing, nil)
 	case "json":
 		scrubJson(models, cfg.Scrubbing, nil)
 	case "mysql":
-		scrubMysql(models, cfg.Scrubbing, nil)
+		scrubMysql(models, cfg.Scrubbing, cfg.Filtering, nil)
+	case "ndjson":
+		scrubNdjson(models, cfg.Scrubbing, nil)
+	case "postgres":
+		scrubPostgres(models, cfg.Scrubbing, nil)
+	case "sqlite":
+		scrubSqlite(models, cfg.Scrubbing, nil)
 	default:
 		// should never happen (cobra should validate)
 		panic("unknown mode: " + modeFlag)
 	}
+
+	if err := cfg.Scrubbing.CloseMappings(); err != nil {
+		ui.Fatalf("Cannot save mapping store: %s", err)
+		ui.Exit('>')
+	}
 }
 
 func scrubJson(models map[string]nlp.Model, pol *scrubbing.Policy, verifier *scrubbing.Verifier) {
@@ -71,14 +104,69 @@ func scrubJson(models map[string]nlp.Model, pol *scrubbing.Policy, verifier *scr
 	// TODO: deal with context (is it useful at all? JSON schema maybe?)
 	sc := scrubbing.NewScrubber(saltFlag, maskFlag, pol, models)
 	sc.Verifier = verifier
+	sc.Classifier = scrubbing.NewClassifier(pol)
 
-	// TODO: parallelize JSON scrubbing
-	scrubjson.Scrub(sc, os.Stdin, os.Stdout)
+	// TODO: parallelize JSON scrubbing (use ndjson mode where possible)
+	// output is buffered so that nothing is written if scrubbing fails
+	buf := new(bytes.Buffer)
+	scrubjson.Scrub(sc, os.Stdin, buf)
+	abortUnclassified(sc.Classifier)
+	buf.WriteTo(os.Stdout)
 }
 
-func scrubMysql(models map[string]nlp.Model, pol *scrubbing.Policy, verifier *scrubbing.Verifier) {
+func scrubNdjson(models map[string]nlp.Model, pol *scrubbing.Policy, verifier *scrubbing.Verifier) {
+	cl := scrubbing.NewClassifier(pol)
+	N := runtime.NumCPU()
+
+	in := make([]chan string, N)
+	out := make([]chan string, N)
+	for i := 0; i < N; i++ {
+		in[i] = make(chan string)
+		out[i] = make(chan string)
+		sc := scrubbing.NewScrubber(saltFlag, maskFlag, pol, models)
+		sc.Verifier = verifier
+		sc.Classifier = cl
+		go scrubjson.ScrubChan(sc, in[i], out[i])
+	}
+	drain := func(to int) {
+		for i := 0; i < to; i++ {
+			output := <-out[i]
+			abortUnclassified(cl)
+			if verifier == nil {
+				// actually produce output when not verifying
+				fmt.Print(output)
+			}
+		}
+	}
+	done := func() {
+		for i := 0; i < N; i++ {
+			close(in[i])
+			close(out[i])
+		}
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	l := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			break
+		}
+
+		in[l] <- line
+		l = (l + 1) % N
+		if l == 0 {
+			drain(N)
+		}
+	}
+	drain(l)
+	done()
+}
+
+func scrubMysql(models map[string]nlp.Model, pol *scrubbing.Policy, filter *filtering.Policy, verifier *scrubbing.Verifier) {
 	// Scan any context provided
 	ctx := mysql.NewContext()
+	ctx.Filter = filter
 	for _, file := range contextFlag {
 		sql, err := ioutil.ReadFile(file)
 		if err != nil {
@@ -87,20 +175,73 @@ func scrubMysql(models map[string]nlp.Model, pol *scrubbing.Policy, verifier *sc
 		ctx.Scan(string(sql))
 	}
 
+	switch strictFlag {
+	case "":
+		ctx.Strict = mysql.Lenient
+	case "abort":
+		ctx.Strict = mysql.Abort
+	case "redact":
+		ctx.Strict = mysql.Redact
+	default:
+		ui.Fatalf("Invalid --strict %q", strictFlag).Hint("must be abort or redact")
+		ui.Exit('-')
+	}
+
+	if pol.Unclassified != "" {
+		if fields := ctx.Unclassified(pol); len(fields) > 0 {
+			switch pol.Unclassified {
+			case "abort":
+				ui.Fatalf("Scrubbing policy does not classify %d fields.", len(fields)).Hint(fields...)
+				ui.Exit('>')
+			case "report":
+				ui.Warnf("Scrubbing policy does not classify %d fields.", len(fields)).Hint(fields...)
+			}
+		}
+	}
+
+	cl := scrubbing.NewClassifier(pol)
 	N := runtime.NumCPU()
 
-	in := make([]chan string, N)
+	in := make([]chan mysql.Statement, N)
 	out := make([]chan string, N)
 	for i := 0; i < N; i++ {
-		in[i] = make(chan string)
+		in[i] = make(chan mysql.Statement)
 		out[i] = make(chan string)
 		sc := scrubbing.NewScrubber(saltFlag, maskFlag, pol, models)
 		sc.Verifier = verifier
+		sc.Classifier = cl
 		go mysql.ScrubChan(ctx, sc, in[i], out[i])
 	}
+
+	// the input may have been copied to a temporary file for subsetting
+	cleanup := func() {}
+	abort := func() {
+		switch err := context.Cause(ctx).(type) {
+		case nil:
+			return
+		case *mysql.UnparseableError:
+			ui.Fatal(err).Hint("input may be malformed; omit --strict or use --strict=redact to proceed")
+		case *scrubbing.UnclassifiedError:
+			ui.Fatal(err).Hint("add a field-name rule for this field (use \"pass\" if it is safe)")
+		case *scrubbing.ConditionError:
+			ui.Fatal(err).Hint(conditionHint, "provide the table's schema with --context if INSERTs omit column names")
+		case *scrubbing.DeriveError:
+			ui.Fatal(err).Hint(deriveHint, "provide the table's schema with --context if INSERTs omit column names")
+		case *filtering.UnknownColumnError:
+			ui.Fatal(err).Hint("check the where and key of this table's filter", "provide the table's schema with --context if INSERTs omit column names")
+		default:
+			ui.Fatal(err)
+		}
+		cleanup()
+		ui.Exit('>')
+	}
+
+	// output is checked before it is written, so that nothing is written
+	// once scrubbing has failed
 	drain := func(to int) {
 		for i := 0; i < to; i++ {
 			output := <-out[i]
+			abort()
 			if verifier == nil {
 				// actually produce output when not verifying
 				fmt.Print(output)
@@ -114,13 +255,110 @@ func scrubMysql(models map[string]nlp.Model, pol *scrubbing.Policy, verifier *sc
 		}
 	}
 
-	reader := bufio.NewReader(os.Stdin)
+	var input io.Reader = os.Stdin
+	if filter != nil && filter.Cascade {
+		rs, release, err := rewindable(os.Stdin)
+		if err != nil {
+			ui.Fatalf("Cannot buffer input for subsetting: %s", err)
+			ui.Exit('>')
+		}
+		cleanup = release
+		defer cleanup()
+		if err := ctx.Subset(rs); err != nil {
+			cleanup()
+			ui.Fatal(err).Hint("check the filtering policy")
+			ui.Exit('>')
+		}
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			cleanup()
+			ui.Fatal(err)
+			ui.Exit('>')
+		}
+		input = rs
+	}
+
+	reader := mysql.NewReader(ctx, input)
 	l := 0
 	for {
-		line, err := reader.ReadString('\n')
+		stmt, err := reader.ReadStatement()
+		if err != nil {
+			break
+		}
+
+		in[l] <- stmt
+		l = (l + 1) % N
+		if l == 0 {
+			drain(N)
+		}
+	}
+	drain(l)
+	done()
+}
+
+func scrubPostgres(models map[string]nlp.Model, pol *scrubbing.Policy, verifier *scrubbing.Verifier) {
+	// Scan any context provided
+	ctx := postgres.NewContext()
+	for _, file := range contextFlag {
+		sql, err := ioutil.ReadFile(file)
+		if err != nil {
+			ui.Fatal(err)
+			ui.Exit('>')
+		}
+		ctx.Scan(string(sql))
+	}
+
+	cl := scrubbing.NewClassifier(pol)
+	N := runtime.NumCPU()
+
+	scs := make([]*scrubbing.Scrubber, N)
+	in := make([]chan postgres.Line, N)
+	out := make([]chan string, N)
+	for i := 0; i < N; i++ {
+		in[i] = make(chan postgres.Line)
+		out[i] = make(chan string)
+		sc := scrubbing.NewScrubber(saltFlag, maskFlag, pol, models)
+		sc.Verifier = verifier
+		sc.Classifier = cl
+		scs[i] = sc
+		go postgres.ScrubChan(sc, in[i], out[i])
+	}
+	drain := func(to int) {
+		for i := 0; i < to; i++ {
+			output := <-out[i]
+			abortUnclassified(cl)
+			abortFailed(scs)
+			if verifier == nil {
+				// actually produce output when not verifying
+				fmt.Print(output)
+			}
+		}
+	}
+	done := func() {
+		for i := 0; i < N; i++ {
+			close(in[i])
+			close(out[i])
+		}
+	}
+
+	reader := postgres.NewReader(ctx, os.Stdin)
+	warned := false
+	l := 0
+	for {
+		line, err := reader.ReadLine()
 		if err != nil {
 			break
 		}
+		if line.Insert {
+			// only COPY data is scrubbed
+			if strictFlag != "" {
+				drain(l)
+				ui.Fatalf("Cannot scrub INSERT statements").Hint("dump without pg_dump --inserts so that data is in COPY blocks")
+				ui.Exit('>')
+			} else if !warned {
+				ui.Warnf("INSERT statements are not scrubbed").Hint("dump without pg_dump --inserts so that data is in COPY blocks", "use --strict to stop instead")
+				warned = true
+			}
+		}
 
 		in[l] <- line
 		l = (l + 1) % N
@@ -131,3 +369,201 @@ func scrubMysql(models map[string]nlp.Model, pol *scrubbing.Policy, verifier *sc
 	drain(l)
 	done()
 }
+
+func scrubSqlite(models map[string]nlp.Model, pol *scrubbing.Policy, verifier *scrubbing.Verifier) {
+	// Scan any context provided
+	ctx := sqlite.NewContext()
+	for _, file := range contextFlag {
+		sql, err := ioutil.ReadFile(file)
+		if err != nil {
+			ui.Fatal(err)
+			ui.Exit('>')
+		}
+		ctx.Scan(string(sql))
+	}
+
+	cl := scrubbing.NewClassifier(pol)
+	N := runtime.NumCPU()
+
+	scs := make([]*scrubbing.Scrubber, N)
+	in := make([]chan string, N)
+	out := make([]chan string, N)
+	for i := 0; i < N; i++ {
+		in[i] = make(chan string)
+		out[i] = make(chan string)
+		sc := scrubbing.NewScrubber(saltFlag, maskFlag, pol, models)
+		sc.Verifier = verifier
+		sc.Classifier = cl
+		scs[i] = sc
+		go sqlite.ScrubChan(ctx, sc, in[i], out[i])
+	}
+	drain := func(to int) {
+		for i := 0; i < to; i++ {
+			output := <-out[i]
+			abortUnclassified(cl)
+			abortFailed(scs)
+			if verifier == nil {
+				// actually produce output when not verifying
+				fmt.Print(output)
+			}
+		}
+	}
+	done := func() {
+		for i := 0; i < N; i++ {
+			close(in[i])
+			close(out[i])
+		}
+	}
+
+	reader := sqlite.NewReader(ctx, os.Stdin)
+	l := 0
+	for {
+		stmt, err := reader.ReadStatement()
+		if err != nil {
+			break
+		}
+
+		in[l] <- stmt
+		l = (l + 1) % N
+		if l == 0 {
+			drain(N)
+		}
+	}
+	drain(l)
+	done()
+}
+
+func scrubCsv(models map[string]nlp.Model, pol *scrubbing.Policy, verifier *scrubbing.Verifier) {
+	ctx := newCsvContext()
+	reader := csv.NewReader(ctx, os.Stdin)
+	if ctx.Header {
+		header, err := reader.ReadHeader()
+		if err != nil {
+			if err != io.EOF {
+				abortUnreadable(err)
+			}
+			return
+		}
+		if verifier == nil {
+			fmt.Print(header)
+		}
+	}
+
+	cl := scrubbing.NewClassifier(pol)
+	N := runtime.NumCPU()
+
+	scs := make([]*scrubbing.Scrubber, N)
+	in := make([]chan string, N)
+	out := make([]chan string, N)
+	for i := 0; i < N; i++ {
+		in[i] = make(chan string)
+		out[i] = make(chan string)
+		sc := scrubbing.NewScrubber(saltFlag, maskFlag, pol, models)
+		sc.Verifier = verifier
+		sc.Classifier = cl
+		scs[i] = sc
+		go csv.ScrubChan(ctx, sc, in[i], out[i])
+	}
+	drain := func(to int) {
+		for i := 0; i < to; i++ {
+			output := <-out[i]
+			abortUnclassified(cl)
+			abortFailed(scs)
+			if verifier == nil {
+				// actually produce output when not verifying
+				fmt.Print(output)
+			}
+		}
+	}
+	done := func() {
+		for i := 0; i < N; i++ {
+			close(in[i])
+			close(out[i])
+		}
+	}
+
+	l := 0
+	for {
+		record, err := reader.ReadRecord()
+		if err != nil {
+			if err != io.EOF {
+				drain(l)
+				abortUnreadable(err)
+			}
+			break
+		}
+
+		in[l] <- record
+		l = (l + 1) % N
+		if l == 0 {
+			drain(N)
+		}
+	}
+	drain(l)
+	done()
+}
+
+// Stops the program if cl has seen an unclassified field and the policy
+// says to abort.
+func abortUnclassified(cl *scrubbing.Classifier) {
+	if err := cl.Err(); err != nil {
+		ui.Fatal(err).Hint("add a field-name rule for this field (use \"pass\" if it is safe)")
+		ui.Exit('>')
+	}
+}
+
+// Stops the program because its input cannot be read or split into
+// records.
+func abortUnreadable(err error) {
+	ui.Fatal(err).Hint("input may be malformed; check for a quote that is never closed")
+	ui.Exit('>')
+}
+
+// Hints for a *scrubbing.ConditionError and a *scrubbing.DeriveError.
+const (
+	conditionHint = "check the when of this rule for typos"
+	deriveHint    = "check the placeholders of this template for typos"
+)
+
+// Stops the program if any of the scrubbers has failed to apply the policy
+// (e.g. because of a rule's condition).
+func abortFailed(scs []*scrubbing.Scrubber) {
+	for _, sc := range scs {
+		if err := sc.Err(); err != nil {
+			var de *scrubbing.DeriveError
+			if errors.As(err, &de) {
+				ui.Fatal(err).Hint(deriveHint)
+			} else {
+				ui.Fatal(err).Hint(conditionHint)
+			}
+			ui.Exit('>')
+		}
+	}
+}
+
+// Returns a version of f that can be read more than once: f itself if it is
+// a regular file, or else a temporary copy of it. Call cleanup when done.
+func rewindable(f *os.File) (io.ReadSeeker, func(), error) {
+	if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+		if _, err := f.Seek(0, io.SeekCurrent); err == nil {
+			return f, func() {}, nil
+		}
+	}
+	tmp, err := os.CreateTemp("", "pipeclean-*.sql")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	if _, err := io.Copy(tmp, f); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return tmp, cleanup, nil
+}
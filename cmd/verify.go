@@ -48,10 +48,18 @@ func verify(cmd *cobra.Command, args []string) {
 	verifier := scrubbing.NewVerifier(cfg.Scrubbing)
 
 	switch modeFlag {
+	case "csv":
+		scrubCsv(models, cfg.Scrubbing, verifier)
 	case "json":
 		scrubJson(models, cfg.Scrubbing, verifier)
 	case "mysql":
-		scrubMysql(models, cfg.Scrubbing, verifier)
+		scrubMysql(models, cfg.Scrubbing, cfg.Filtering, verifier)
+	case "ndjson":
+		scrubNdjson(models, cfg.Scrubbing, verifier)
+	case "postgres":
+		scrubPostgres(models, cfg.Scrubbing, verifier)
+	case "sqlite":
+		scrubSqlite(models, cfg.Scrubbing, verifier)
 	default:
 		// should never happen (cobra should validate)
 		panic("unknown mode: " + modeFlag)
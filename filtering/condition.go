@@ -0,0 +1,85 @@
+package filtering
+
+import (
+	"encoding/json"
+
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/test_driver"
+)
+
+// Condition is an SQL condition on the fields of a record, such as the
+// where of a Table, e.g. "deleted_at IS NULL AND kind = 'company'".
+type Condition struct {
+	source string
+	expr   ast.ExprNode
+}
+
+// ParseCondition parses an SQL condition, and checks that it only uses the
+// operators that filtering supports.
+func ParseCondition(cond string) (*Condition, error) {
+	expr, err := parseWhere(cond)
+	if err != nil {
+		return nil, err
+	}
+	return &Condition{source: cond, expr: expr}, nil
+}
+
+// MustParseCondition is like ParseCondition but panics if the condition
+// cannot be parsed.
+func MustParseCondition(cond string) *Condition {
+	c, err := ParseCondition(cond)
+	if err != nil {
+		panic(`filtering: ParseCondition(` + cond + `): ` + err.Error())
+	}
+	return c
+}
+
+func (c *Condition) String() string {
+	return c.source
+}
+
+func (c *Condition) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.source)
+}
+
+func (c *Condition) UnmarshalJSON(b []byte) error {
+	var source string
+	if err := json.Unmarshal(b, &source); err != nil {
+		return err
+	}
+	parsed, err := ParseCondition(source)
+	if err != nil {
+		return err
+	}
+	*c = *parsed
+	return nil
+}
+
+// Eval reports whether a row of the named table satisfies the condition. As
+// in SQL, a condition that evaluates to NULL is not satisfied.
+func (c *Condition) Eval(table string, row Lookup) (bool, error) {
+	v, err := eval(c.expr, table, row)
+	if err != nil || v == nil {
+		return false, err
+	}
+	return truth(v), nil
+}
+
+// Strings returns a Lookup of string values, such as the fields of a CSV
+// record; fields whose value is nil are NULL, and fields that are absent
+// from values are unknown.
+func Strings(values map[string]*string) Lookup {
+	return func(column string) (ast.ExprNode, bool) {
+		s, ok := values[column]
+		if !ok {
+			return nil, false
+		}
+		expr := &test_driver.ValueExpr{}
+		if s != nil {
+			expr.SetString(*s)
+		} else {
+			expr.SetNull()
+		}
+		return expr, true
+	}
+}
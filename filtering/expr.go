@@ -0,0 +1,351 @@
+package filtering
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/opcode"
+	"github.com/pingcap/tidb/parser/test_driver"
+)
+
+// Lookup returns the value of a column of the row being filtered, given the
+// (lower-case) name of the column.
+type Lookup func(column string) (ast.ExprNode, bool)
+
+// UnknownColumnError indicates that a filter (or the condition of a
+// field-name rule) refers to a column that a row does not have.
+type UnknownColumnError struct {
+	Table, Column string
+}
+
+func (e *UnknownColumnError) Error() string {
+	if e.Table == "" {
+		// a condition of a field-name rule, which may apply to any table
+		return fmt.Sprintf("condition refers to unknown field %s", e.Column)
+	}
+	return fmt.Sprintf("filter for table %s refers to unknown column %s", e.Table, e.Column)
+}
+
+// The value of an expression: nil (SQL NULL), a string, a number (*big.Rat)
+// or a bool.
+type value any
+
+// Checks that an expression only uses the operators that eval supports.
+func check(expr ast.ExprNode) error {
+	var err error
+	each := func(exprs ...ast.ExprNode) {
+		for _, e := range exprs {
+			if err == nil {
+				err = check(e)
+			}
+		}
+	}
+	switch typed := expr.(type) {
+	case *test_driver.ValueExpr:
+		switch typed.Kind() {
+		case test_driver.KindNull, test_driver.KindString, test_driver.KindInt64, test_driver.KindUint64,
+			test_driver.KindMysqlDecimal, test_driver.KindFloat32, test_driver.KindFloat64:
+		default:
+			return fmt.Errorf("unsupported literal %s", restore(expr))
+		}
+	case *ast.ColumnNameExpr:
+	case *ast.ParenthesesExpr:
+		each(typed.Expr)
+	case *ast.IsNullExpr:
+		each(typed.Expr)
+	case *ast.PatternInExpr:
+		if typed.Sel != nil {
+			return fmt.Errorf("unsupported subquery")
+		}
+		each(typed.Expr)
+		each(typed.List...)
+	case *ast.BetweenExpr:
+		each(typed.Expr, typed.Left, typed.Right)
+	case *ast.PatternLikeOrIlikeExpr:
+		each(typed.Expr, typed.Pattern)
+	case *ast.UnaryOperationExpr:
+		switch typed.Op {
+		case opcode.Not, opcode.Not2, opcode.Minus:
+		default:
+			return fmt.Errorf("unsupported operator %s", typed.Op)
+		}
+		each(typed.V)
+	case *ast.BinaryOperationExpr:
+		switch typed.Op {
+		case opcode.LogicAnd, opcode.LogicOr, opcode.EQ, opcode.NE, opcode.LT, opcode.LE, opcode.GT, opcode.GE, opcode.NullEQ:
+		default:
+			return fmt.Errorf("unsupported operator %s", typed.Op)
+		}
+		each(typed.L, typed.R)
+	default:
+		return fmt.Errorf("unsupported expression %s", restore(expr))
+	}
+	return err
+}
+
+// Evaluates an expression that has passed check, with SQL semantics for
+// NULL (e.g. NULL = NULL is NULL, and NULL OR TRUE is TRUE).
+func eval(expr ast.ExprNode, table string, row Lookup) (value, error) {
+	switch typed := expr.(type) {
+	case *test_driver.ValueExpr:
+		switch typed.Kind() {
+		case test_driver.KindString:
+			return typed.GetString(), nil
+		case test_driver.KindInt64, test_driver.KindUint64, test_driver.KindMysqlDecimal, test_driver.KindFloat32, test_driver.KindFloat64:
+			var s string
+			switch typed.Kind() {
+			case test_driver.KindInt64:
+				s = fmt.Sprint(typed.GetInt64())
+			case test_driver.KindUint64:
+				s = fmt.Sprint(typed.GetUint64())
+			case test_driver.KindMysqlDecimal:
+				s = typed.GetMysqlDecimal().String()
+			default:
+				s = fmt.Sprint(typed.GetFloat64())
+			}
+			n, _ := new(big.Rat).SetString(s)
+			return n, nil
+		}
+		return nil, nil
+	case *ast.ColumnNameExpr:
+		v, ok := row(typed.Name.Name.L)
+		if !ok {
+			return nil, &UnknownColumnError{Table: table, Column: typed.Name.Name.O}
+		}
+		if err := check(v); err != nil {
+			// not a literal, e.g. a function call in a hand-written INSERT
+			return nil, nil
+		}
+		return eval(v, table, row)
+	case *ast.ParenthesesExpr:
+		return eval(typed.Expr, table, row)
+	case *ast.IsNullExpr:
+		v, err := eval(typed.Expr, table, row)
+		return (v == nil) != typed.Not, err
+	case *ast.PatternInExpr:
+		v, err := eval(typed.Expr, table, row)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		var result value = false
+		for _, item := range typed.List {
+			w, err := eval(item, table, row)
+			if err != nil {
+				return nil, err
+			}
+			if w == nil {
+				result = nil
+			} else if compare(v, w) == 0 {
+				result = true
+				break
+			}
+		}
+		return not(result, typed.Not), nil
+	case *ast.BetweenExpr:
+		vals, err := evalAll(table, row, typed.Expr, typed.Left, typed.Right)
+		if err != nil || vals[0] == nil || vals[1] == nil || vals[2] == nil {
+			return nil, err
+		}
+		return not(compare(vals[0], vals[1]) >= 0 && compare(vals[0], vals[2]) <= 0, typed.Not), nil
+	case *ast.PatternLikeOrIlikeExpr:
+		vals, err := evalAll(table, row, typed.Expr, typed.Pattern)
+		if err != nil || vals[0] == nil || vals[1] == nil {
+			return nil, err
+		}
+		matched := like(text(vals[0]), text(vals[1]), typed.Escape, !typed.IsLike)
+		return not(matched, typed.Not), nil
+	case *ast.UnaryOperationExpr:
+		v, err := eval(typed.V, table, row)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		if typed.Op == opcode.Minus {
+			return new(big.Rat).Neg(number(v)), nil
+		}
+		return !truth(v), nil
+	case *ast.BinaryOperationExpr:
+		l, err := eval(typed.L, table, row)
+		if err != nil {
+			return nil, err
+		}
+		switch typed.Op {
+		case opcode.LogicAnd:
+			if l != nil && !truth(l) {
+				return false, nil
+			}
+		case opcode.LogicOr:
+			if l != nil && truth(l) {
+				return true, nil
+			}
+		}
+		r, err := eval(typed.R, table, row)
+		if err != nil {
+			return nil, err
+		}
+		switch typed.Op {
+		case opcode.LogicAnd:
+			if r != nil && !truth(r) {
+				return false, nil
+			} else if l == nil || r == nil {
+				return nil, nil
+			}
+			return true, nil
+		case opcode.LogicOr:
+			if r != nil && truth(r) {
+				return true, nil
+			} else if l == nil || r == nil {
+				return nil, nil
+			}
+			return false, nil
+		case opcode.NullEQ:
+			if l == nil || r == nil {
+				return l == nil && r == nil, nil
+			}
+			return compare(l, r) == 0, nil
+		}
+		if l == nil || r == nil {
+			return nil, nil
+		}
+		c := compare(l, r)
+		switch typed.Op {
+		case opcode.EQ:
+			return c == 0, nil
+		case opcode.NE:
+			return c != 0, nil
+		case opcode.LT:
+			return c < 0, nil
+		case opcode.LE:
+			return c <= 0, nil
+		case opcode.GT:
+			return c > 0, nil
+		case opcode.GE:
+			return c >= 0, nil
+		}
+	}
+	// should never happen for expressions that have passed check
+	return nil, fmt.Errorf("unsupported expression %s", restore(expr))
+}
+
+func evalAll(table string, row Lookup, exprs ...ast.ExprNode) ([]value, error) {
+	vals := make([]value, len(exprs))
+	for i, e := range exprs {
+		v, err := eval(e, table, row)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// Negates a boolean value if not is true; NULL stays NULL.
+func not(v value, not bool) value {
+	if b, ok := v.(bool); ok {
+		return b != not
+	}
+	return v
+}
+
+// Converts a non-NULL value to a number; strings that are not numbers are 0
+// (as in MySQL) and booleans are 1 or 0.
+func number(v value) *big.Rat {
+	switch typed := v.(type) {
+	case *big.Rat:
+		return typed
+	case string:
+		if n, ok := new(big.Rat).SetString(strings.TrimSpace(typed)); ok {
+			return n
+		}
+	case bool:
+		if typed {
+			return big.NewRat(1, 1)
+		}
+	}
+	return new(big.Rat)
+}
+
+// Reports whether a non-NULL value is true.
+func truth(v value) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return number(v).Sign() != 0
+}
+
+// Converts a non-NULL value to a string.
+func text(v value) string {
+	switch typed := v.(type) {
+	case string:
+		return typed
+	case *big.Rat:
+		return ratString(typed)
+	case bool:
+		if typed {
+			return "1"
+		}
+	}
+	return "0"
+}
+
+// Formats a number without superfluous decimal places.
+func ratString(n *big.Rat) string {
+	if n.IsInt() {
+		return n.Num().String()
+	}
+	return strings.TrimRight(n.FloatString(20), "0")
+}
+
+// Compares two non-NULL values: numerically if either is a number or
+// boolean, and otherwise as strings (byte by byte).
+func compare(a, b value) int {
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if aok && bok {
+		return strings.Compare(as, bs)
+	}
+	return number(a).Cmp(number(b))
+}
+
+// Matches a string against a LIKE pattern, in which % matches any sequence
+// of characters and _ matches any single character.
+func like(s, pattern string, escape byte, fold bool) bool {
+	if fold {
+		s, pattern = strings.ToLower(s), strings.ToLower(pattern)
+	}
+	if escape == 0 {
+		escape = '\\'
+	}
+	sr, pr := []rune(s), []rune(pattern)
+	var match func(i, j int) bool
+	match = func(i, j int) bool {
+		for j < len(pr) {
+			switch c := pr[j]; {
+			case c == '%':
+				for k := i; k <= len(sr); k++ {
+					if match(k, j+1) {
+						return true
+					}
+				}
+				return false
+			case c == '_':
+				if i >= len(sr) {
+					return false
+				}
+			default:
+				if c == rune(escape) && j+1 < len(pr) {
+					j++
+					c = pr[j]
+				}
+				if i >= len(sr) || sr[i] != c {
+					return false
+				}
+			}
+			i++
+			j++
+		}
+		return i == len(sr)
+	}
+	return match(0, 0)
+}
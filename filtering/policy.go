@@ -0,0 +1,172 @@
+package filtering
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/format"
+)
+
+// Policy decides which rows of a data set to keep, so that a subset of the
+// data can be produced in the same pass as scrubbing.
+type Policy struct {
+	// Tables holds a filter for each table whose rows should be filtered;
+	// rows of other tables are always kept.
+	// Key: table name
+	// Value: filter for rows of the table
+	Tables map[string]*Table `json:"tables"`
+	// Cascade omits rows that refer (by foreign key) to omitted rows, so
+	// that the subset is referentially intact. Filters then select a seed
+	// set of parent rows, and only the child rows of that set are kept.
+	Cascade bool `json:"cascade"`
+}
+
+// Table filters the rows of one table.
+type Table struct {
+	// Where is an SQL condition that rows must satisfy to be kept, e.g.
+	// "deleted_at IS NULL", as with mysqldump --where.
+	Where string `json:"where"`
+	// Sample is the percentage of rows to keep (after applying Where),
+	// between 0 and 100; 0 means all rows.
+	Sample float64 `json:"sample"`
+	// Key names the columns that identify a row for sampling; by default,
+	// the table's primary key.
+	Key []string `json:"key"`
+
+	where *Condition
+}
+
+// Validate checks that the policy is internally consistent, and prepares it
+// for use. It must be called before filtering.
+func (p *Policy) Validate() []error {
+	var errs []error
+
+	names := make([]string, 0, len(p.Tables))
+	for name := range p.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tables := make(map[string]*Table, len(p.Tables))
+	for _, name := range names {
+		t := p.Tables[name]
+		if t == nil {
+			errs = append(errs, fmt.Errorf("missing filter for table %s", name))
+			continue
+		}
+		if t.Where != "" {
+			where, err := ParseCondition(t.Where)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%w in where for table %s", err, name))
+			}
+			t.where = where
+		}
+		if t.Sample < 0 || t.Sample > 100 {
+			errs = append(errs, fmt.Errorf("sample for table %s must be a percentage between 0 and 100", name))
+		}
+		for i := range t.Key {
+			t.Key[i] = strings.ToLower(t.Key[i])
+		}
+		tables[strings.ToLower(name)] = t
+	}
+	p.Tables = tables
+
+	return errs
+}
+
+// Parses and checks an SQL condition.
+func parseWhere(cond string) (ast.ExprNode, error) {
+	stmt, err := parser.New().ParseOneStmt("SELECT 1 FROM t WHERE "+cond, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("syntax error")
+	}
+	sel, ok := stmt.(*ast.SelectStmt)
+	if !ok || sel.Where == nil || sel.Limit != nil || sel.OrderBy != nil || sel.GroupBy != nil {
+		return nil, fmt.Errorf("syntax error")
+	}
+	if err := check(sel.Where); err != nil {
+		return nil, err
+	}
+	return sel.Where, nil
+}
+
+// Table returns the filter for a table, or nil if its rows are always kept.
+func (p *Policy) Table(name string) *Table {
+	if p == nil {
+		return nil
+	}
+	return p.Tables[name]
+}
+
+// Keep decides whether to keep a row of the named table. If the filter has
+// no Key, the row is identified by the values of the columns named by
+// primaryKey. Sampling fails if neither names any column.
+func (t *Table) Keep(table string, row Lookup, primaryKey []string) (bool, error) {
+	if t.where != nil {
+		if ok, err := t.where.Eval(table, row); err != nil || !ok {
+			return false, err
+		}
+	}
+
+	if t.Sample > 0 && t.Sample < 100 {
+		key := t.Key
+		if len(key) == 0 {
+			key = primaryKey
+		}
+		id, err := identify(table, row, key)
+		if err != nil {
+			return false, err
+		}
+		return sampled(id, t.Sample), nil
+	}
+
+	return true, nil
+}
+
+// Returns a string that identifies a row by the values of its key columns.
+func identify(table string, row Lookup, key []string) (string, error) {
+	if len(key) == 0 {
+		// every row would be identified alike, and kept or omitted together
+		return "", fmt.Errorf("%s: no key to sample rows by", table)
+	}
+	vals := make([]string, len(key))
+	for i, col := range key {
+		expr, ok := row(col)
+		if !ok {
+			return "", &UnknownColumnError{Table: table, Column: col}
+		}
+		v, err := eval(expr, table, row)
+		if err != nil {
+			return "", err
+		}
+		if v != nil {
+			vals[i] = text(v)
+		}
+	}
+	return strings.Join(vals, "\x00"), nil
+}
+
+// Decides whether a row belongs to a sample of the given percentage. The
+// decision depends only on the row's identity, so that tables which share a
+// key (e.g. users.id and orders.user_id) are sampled alike.
+func sampled(id string, pct float64) bool {
+	// FNV (rand.Hash) is too biased for short keys such as sequential IDs
+	sum := sha256.Sum256([]byte(id))
+	return float64(binary.BigEndian.Uint64(sum[:])>>11) < pct/100*(1<<53)
+}
+
+// Turns an expression back into a string, for error messages.
+func restore(expr ast.Node) string {
+	buf := new(bytes.Buffer)
+	ctx := format.NewRestoreCtx(format.RestoreKeyWordUppercase|format.RestoreNameBackQuotes|format.RestoreStringSingleQuotes, buf)
+	if err := expr.Restore(ctx); err != nil {
+		return fmt.Sprintf("%T", expr)
+	}
+	return buf.String()
+}
@@ -0,0 +1,118 @@
+package filtering_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/test_driver"
+	"github.com/xeger/pipeclean/filtering"
+)
+
+func lookup(row map[string]any) filtering.Lookup {
+	return func(column string) (ast.ExprNode, bool) {
+		v, ok := row[column]
+		if !ok {
+			return nil, false
+		}
+		return ast.NewValueExpr(v, "", ""), true
+	}
+}
+
+func decimal(s string) *test_driver.MyDecimal {
+	d := new(test_driver.MyDecimal)
+	if err := d.FromString([]byte(s)); err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestWhere(t *testing.T) {
+	row := lookup(map[string]any{
+		"id":         int64(42),
+		"email":      "joe@example.com",
+		"deleted_at": nil,
+		"balance":    decimal("-12.50"),
+		"status":     "active",
+	})
+	cases := map[string]bool{
+		"deleted_at IS NULL":                     true,
+		"deleted_at IS NOT NULL":                 false,
+		"deleted_at = NULL":                      false,
+		"deleted_at <=> NULL":                    true,
+		"id = 42 AND status = 'active'":          true,
+		"id > 100 OR email LIKE '%@example.com'": true,
+		"email NOT LIKE 'joe%'":                  false,
+		"email LIKE 'JOE%'":                      false,
+		"email ILIKE 'JOE%'":                     true,
+		"status IN ('active', 'trial')":          true,
+		"status NOT IN ('banned', NULL)":         false,
+		"id BETWEEN 40 AND 50":                   true,
+		"balance < 0 AND balance >= -12.5":       true,
+		"NOT (id <> 42)":                         true,
+		"deleted_at > '2020-01-01' OR id = 42":   true,
+		"deleted_at > '2020-01-01' AND id = 42":  false,
+		"id = '42'":                              true,
+	}
+	for where, exp := range cases {
+		pol := &filtering.Policy{Tables: map[string]*filtering.Table{"users": {Where: where}}}
+		if errs := pol.Validate(); errs != nil {
+			t.Errorf("Validate(%q): %v", where, errs)
+			continue
+		}
+		got, err := pol.Table("users").Keep("users", row, nil)
+		if err != nil {
+			t.Errorf("Keep(%q): %s", where, err)
+		} else if got != exp {
+			t.Errorf("Keep(%q) = %v, want %v", where, got, exp)
+		}
+	}
+
+	pol := &filtering.Policy{Tables: map[string]*filtering.Table{"users": {Where: "nope IS NULL"}}}
+	pol.Validate()
+	if _, err := pol.Table("users").Keep("users", row, nil); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Errorf("Keep accepted unknown column: %v", err)
+	}
+
+	for _, bad := range []string{"id IN (SELECT id FROM admins)", "UPPER(email) = 'X'", "id = ", "id + 1 = 2"} {
+		pol := &filtering.Policy{Tables: map[string]*filtering.Table{"users": {Where: bad}}}
+		if errs := pol.Validate(); errs == nil {
+			t.Errorf("Validate accepted %q", bad)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	pol := &filtering.Policy{Tables: map[string]*filtering.Table{
+		"Users":  {Sample: 10},
+		"orders": {Sample: 10, Key: []string{"User_ID"}},
+	}}
+	if errs := pol.Validate(); errs != nil {
+		t.Fatalf("Validate: %v", errs)
+	}
+	kept := 0
+	for id := int64(1); id <= 1000; id++ {
+		user, err := pol.Table("users").Keep("users", lookup(map[string]any{"id": id}), []string{"id"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		order, err := pol.Table("orders").Keep("orders", lookup(map[string]any{"id": id * 7, "user_id": id}), []string{"id"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user != order {
+			t.Errorf("user %d kept = %v, but their order kept = %v", id, user, order)
+		}
+		if user {
+			kept++
+		}
+	}
+	if kept < 70 || kept > 130 {
+		t.Errorf("kept %d of 1000 rows, want about 100", kept)
+	}
+
+	pol.Tables["users"].Sample = 101
+	if errs := pol.Validate(); errs == nil {
+		t.Errorf("Validate accepted sample of 101%%")
+	}
+}
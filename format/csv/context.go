@@ -0,0 +1,58 @@
+package csv
+
+import "fmt"
+
+// Context describes the dialect and structure of delimited input data,
+// which is needed to scrub it.
+type Context struct {
+	// Delimiter separates fields within a record (e.g. ',' or '\t').
+	Delimiter byte
+	// Quote encloses fields that contain delimiters, quotes or newlines.
+	// Within a quoted field, the quote character is escaped by doubling it.
+	Quote byte
+	// Header indicates that the first record contains column names.
+	Header bool
+	// TableName is used as a prefix when naming fields (e.g. "users" for "users.email").
+	TableName string
+	// ColumnNames is populated from the header record, if present.
+	ColumnNames []string
+}
+
+// NewContext returns a Context for comma-separated data with a header row.
+func NewContext() *Context {
+	return &Context{
+		Delimiter: ',',
+		Quote:     '"',
+		Header:    true,
+	}
+}
+
+// Names returns a list of column names that apply to the colIdx'th field
+// of a record. The list contains 0-3 elements depending on the completeness
+// of the information available.
+func (ctx *Context) Names(colIdx int) []string {
+	names := make([]string, 0, 3)
+	if colIdx < len(ctx.ColumnNames) {
+		colName := ctx.ColumnNames[colIdx]
+		names = append(names, colName)
+		if len(ctx.TableName) > 0 {
+			names = append(names, fmt.Sprintf("%s.%s", ctx.TableName, colName))
+		}
+	}
+	if len(ctx.TableName) > 0 {
+		names = append(names, fmt.Sprintf("%s.%d", ctx.TableName, colIdx))
+	}
+	return names
+}
+
+// Returns the name under which to report an unclassified field: its
+// "table.column" name if known, or else its only name (if any).
+func classifiedName(names []string) string {
+	switch len(names) {
+	case 0:
+		return ""
+	case 3:
+		return names[1]
+	}
+	return names[0]
+}
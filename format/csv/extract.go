@@ -0,0 +1,51 @@
+package csv
+
+import (
+	"io"
+)
+
+func extract(ctx *Context, names []string, record string) []string {
+	values := []string{}
+	fields, _, ok := parseRecord(ctx, record)
+	if !ok {
+		return values
+	}
+
+	for i, f := range fields {
+		if matchFieldName(names, ctx.Names(i)) {
+			values = append(values, f.value)
+		}
+	}
+	return values
+}
+
+// Extract prints the value of every field whose name matches one of names,
+// one value per line.
+func Extract(ctx *Context, names []string, r io.Reader, w io.Writer) {
+	reader := NewReader(ctx, r)
+	if ctx.Header {
+		if _, err := reader.ReadHeader(); err != nil {
+			return
+		}
+	}
+	for {
+		record, err := reader.ReadRecord()
+		if err != nil {
+			break
+		}
+		for _, v := range extract(ctx, names, record) {
+			w.Write([]byte(v + "\n"))
+		}
+	}
+}
+
+func matchFieldName(want, got []string) bool {
+	for _, w := range want {
+		for _, g := range got {
+			if w == g {
+				return true
+			}
+		}
+	}
+	return false
+}
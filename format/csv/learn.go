@@ -0,0 +1,37 @@
+package csv
+
+import (
+	"github.com/xeger/pipeclean/nlp"
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+func learn(ctx *Context, models map[string]nlp.Model, policy *scrubbing.Policy, record string) {
+	fields, _, ok := parseRecord(ctx, record)
+	if !ok {
+		return
+	}
+
+	for i, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		disposition, _ := policy.MatchFieldName(ctx.Names(i))
+		disposition = policy.FirstStep(disposition)
+		switch disposition.Action() {
+		case "generate":
+			model := models[disposition.Parameter()]
+			if model != nil {
+				model.Train(f.value)
+			}
+		}
+	}
+}
+
+// LearnChan trains models using records produced by a Reader. Models are
+// not safe for concurrent training, so the caller must not share them
+// between several LearnChan goroutines.
+func LearnChan(ctx *Context, models map[string]nlp.Model, policy *scrubbing.Policy, in <-chan string) {
+	for record := range in {
+		learn(ctx, models, policy, record)
+	}
+}
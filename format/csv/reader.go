@@ -0,0 +1,117 @@
+package csv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Maximum length of a record; a quoted field that is still open after this
+// many bytes is assumed to be unterminated, so that memory usage remains
+// bounded.
+const maxRecordLength = 64 * 1024 * 1024
+
+// UnterminatedError reports a record that ends within a quoted field,
+// either at the end of input or because it exceeds the maximum record
+// length. Such a record cannot be split into fields, so it cannot be
+// scrubbed.
+type UnterminatedError struct {
+	// Line is the (1-based) number of the input line where the record begins.
+	Line int
+}
+
+func (e *UnterminatedError) Error() string {
+	return fmt.Sprintf("unterminated quoted field in record at line %d", e.Line)
+}
+
+// Reader splits delimited input into records. Usually, each line is a
+// record; however, quoted fields may contain newlines, in which case
+// Reader joins several lines so that every string it returns is complete.
+type Reader struct {
+	ctx  *Context
+	br   *bufio.Reader
+	line int
+}
+
+func NewReader(ctx *Context, r io.Reader) *Reader {
+	return &Reader{ctx: ctx, br: bufio.NewReader(r)}
+}
+
+// ReadHeader reads the header record and stores its column names in the
+// Context. It must be called before any other records are read, and only
+// if the Context specifies that a header is present.
+func (r *Reader) ReadHeader() (string, error) {
+	text, err := r.ReadRecord()
+	if err != nil {
+		return "", err
+	}
+	fields, _, _ := parseRecord(r.ctx, text)
+	r.ctx.ColumnNames = make([]string, len(fields))
+	for i, f := range fields {
+		r.ctx.ColumnNames[i] = strings.TrimSpace(f.value)
+	}
+	return text, nil
+}
+
+// ReadRecord returns the next record of input, including its line
+// terminator; it returns io.EOF after the final record has been read, or
+// an *UnterminatedError if a quoted field is never closed.
+func (r *Reader) ReadRecord() (string, error) {
+	var sb strings.Builder
+	qs := quoteState{fieldStart: true}
+	start := r.line + 1
+	for {
+		line, err := r.br.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if sb.Len() > 0 {
+				return "", &UnterminatedError{Line: start}
+			}
+			return "", err
+		}
+		r.line++
+		sb.WriteString(line)
+		if !qs.scan(r.ctx, line) {
+			return sb.String(), nil
+		} else if err == io.EOF || sb.Len() > maxRecordLength {
+			return "", &UnterminatedError{Line: start}
+		}
+	}
+}
+
+// Tracks whether a record, read one line at a time, is still within a
+// quoted field; this follows the same rules as parseRecord.
+type quoteState struct {
+	// quoted is true within a quoted field.
+	quoted bool
+	// closed is true right after a quoted field's closing quote (which
+	// might yet turn out to be the first half of an escaped quote).
+	closed bool
+	// fieldStart is true at the start of a field.
+	fieldStart bool
+}
+
+// Scans the next line of a record and returns true if the record continues
+// on the following line.
+func (qs *quoteState) scan(ctx *Context, line string) bool {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case qs.quoted:
+			if c == ctx.Quote {
+				qs.quoted, qs.closed = false, true
+			}
+			continue
+		case qs.closed && c == ctx.Quote:
+			qs.quoted = true
+		case c == ctx.Delimiter:
+			qs.fieldStart = true
+			qs.closed = false
+			continue
+		case qs.fieldStart && c == ctx.Quote:
+			qs.quoted = true
+		}
+		qs.closed, qs.fieldStart = false, false
+	}
+	return qs.quoted
+}
@@ -0,0 +1,87 @@
+package csv
+
+import (
+	"strings"
+)
+
+// Field is a single field of a record.
+type field struct {
+	// Raw is the field's text as it appeared in the input.
+	raw string
+	// Value is the field's content, with quoting removed.
+	value string
+	// Quoted is true if the field was enclosed in quotes.
+	quoted bool
+}
+
+// Splits a record into fields. Returns false if the record is incomplete
+// (i.e. it ends within a quoted field).
+func parseRecord(ctx *Context, text string) ([]field, string, bool) {
+	row := strings.TrimRight(text, "\r\n")
+	eol := text[len(row):]
+	fields := make([]field, 0, 16)
+
+	for pos := 0; ; {
+		f := field{}
+		start := pos
+		if pos < len(row) && row[pos] == ctx.Quote {
+			var sb strings.Builder
+			f.quoted = true
+			closed := false
+			for pos++; pos < len(row); pos++ {
+				if row[pos] != ctx.Quote {
+					sb.WriteByte(row[pos])
+				} else if pos+1 < len(row) && row[pos+1] == ctx.Quote {
+					sb.WriteByte(ctx.Quote)
+					pos++
+				} else {
+					closed = true
+					pos++
+					break
+				}
+			}
+			if !closed {
+				return nil, "", false
+			}
+			// Tolerate (and preserve) junk between the closing quote and the delimiter.
+			end := strings.IndexByte(row[pos:], ctx.Delimiter)
+			if end < 0 {
+				end = len(row) - pos
+			}
+			sb.WriteString(row[pos : pos+end])
+			pos += end
+			f.value = sb.String()
+		} else {
+			end := strings.IndexByte(row[pos:], ctx.Delimiter)
+			if end < 0 {
+				end = len(row) - pos
+			}
+			pos += end
+			f.value = row[start:pos]
+		}
+		f.raw = row[start:pos]
+		fields = append(fields, f)
+
+		if pos >= len(row) {
+			break
+		}
+		pos++ // skip delimiter
+	}
+
+	return fields, eol, true
+}
+
+// Encodes a value as a field, quoting it if necessary (or if the original
+// field was quoted).
+func encodeField(ctx *Context, s string, quoted bool) string {
+	if !quoted && strings.IndexByte(s, ctx.Delimiter) < 0 && strings.IndexByte(s, ctx.Quote) < 0 && strings.IndexAny(s, "\r\n") < 0 {
+		return s
+	}
+	q := string(ctx.Quote)
+	return q + strings.ReplaceAll(s, q, q+q) + q
+}
+
+// Joins fields into a record.
+func joinRecord(ctx *Context, fields []string, eol string) string {
+	return strings.Join(fields, string(ctx.Delimiter)) + eol
+}
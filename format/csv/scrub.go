@@ -0,0 +1,51 @@
+package csv
+
+import (
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+func scrub(ctx *Context, sc *scrubbing.Scrubber, record string) string {
+	fields, eol, ok := parseRecord(ctx, record)
+	if !ok {
+		// Reader never returns an incomplete record; if one arrives anyway,
+		// its fields are unknown, so none of it can be emitted.
+		return ""
+	}
+
+	if sc.Policy().UsesRows() {
+		row := make(scrubbing.Row, 3*len(fields))
+		for i, f := range fields {
+			if f.value != "" {
+				row.Set(ctx.Names(i), f.value)
+			} else {
+				row.SetNull(ctx.Names(i))
+			}
+		}
+		sc = sc.WithRow(row)
+	}
+
+	out := make([]string, len(fields))
+	for i, f := range fields {
+		out[i] = f.raw
+		names := ctx.Names(i)
+		sc.Classifier.Check(classifiedName(names), names)
+		if f.value == "" {
+			continue
+		}
+		if sc.EraseString(f.value, names) {
+			out[i] = ""
+		} else if s := sc.ScrubString(f.value, names); s != f.value {
+			out[i] = encodeField(ctx, s, f.quoted)
+		}
+	}
+	return joinRecord(ctx, out, eol)
+}
+
+// ScrubChan sanitizes a sequence of records produced by a Reader. It sends
+// one output string for every input string received. This allows the
+// caller to handle parallelism as desired.
+func ScrubChan(ctx *Context, sc *scrubbing.Scrubber, in <-chan string, out chan<- string) {
+	for record := range in {
+		out <- scrub(ctx, sc, record)
+	}
+}
@@ -0,0 +1,162 @@
+package csv_test
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/xeger/pipeclean/format/csv"
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+func read(t *testing.T, name string) string {
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("Failed to read test file %s: %s", name, err)
+	}
+	return string(data)
+}
+
+func scrub(ctx *csv.Context, input string) string {
+	return scrubPolicy(ctx, input, scrubbing.DefaultPolicy())
+}
+
+func scrubPolicy(ctx *csv.Context, input string, policy *scrubbing.Policy) string {
+	return scrubWith(ctx, input, scrubbing.NewScrubber("", false, policy, nil))
+}
+
+func scrubWith(ctx *csv.Context, input string, scrubber *scrubbing.Scrubber) string {
+	reader := csv.NewReader(ctx, strings.NewReader(input))
+	in := make(chan string)
+	out := make(chan string)
+	output := new(strings.Builder)
+
+	if ctx.Header {
+		header, _ := reader.ReadHeader()
+		output.WriteString(header)
+	}
+
+	go csv.ScrubChan(ctx, scrubber, in, out)
+
+	for {
+		record, err := reader.ReadRecord()
+		if err != nil {
+			break
+		}
+		in <- record
+		output.WriteString(<-out)
+	}
+	close(in)
+	close(out)
+
+	return output.String()
+}
+
+func TestHeader(t *testing.T) {
+	input := read(t, "users.csv")
+	output := scrub(csv.NewContext(), input)
+
+	expected := "id,email,\"Note\"\n1,jyv@iws.com,\"multi\nline, \"\"quoted\"\"\"\n2,\"hruhlic@mzovvt.com\",plain\n"
+	if output != expected {
+		t.Errorf("scrub() = %q, want %q", output, expected)
+	}
+}
+
+func TestTableName(t *testing.T) {
+	input := read(t, "users.csv")
+	ctx := csv.NewContext()
+	ctx.TableName = "users"
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^users\.Note$`), Out: "replace(a,b)"},
+			{In: regexp.MustCompile(`^users\.0$`), Out: "erase"},
+		},
+	}
+	output := scrubPolicy(ctx, input, policy)
+
+	expected := "id,email,\"Note\"\n,joe@foo.com,\"a,b\"\n,\"gophers@google.com\",\"a,b\"\n"
+	if output != expected {
+		t.Errorf("scrub() = %q, want %q", output, expected)
+	}
+}
+
+func TestNoHeader(t *testing.T) {
+	ctx := csv.NewContext()
+	ctx.Delimiter = '\t'
+	ctx.Quote = '\''
+	ctx.Header = false
+	ctx.TableName = "users"
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^users\.1$`), Out: "mask"},
+		},
+	}
+	output := scrubPolicy(ctx, "1\t'joe@foo.com'\t'it''s\n'\n", policy)
+
+	expected := "1\t'jyv@iws.com'\t'it''s\n'\n"
+	if output != expected {
+		t.Errorf("scrub() = %q, want %q", output, expected)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	input := read(t, "users.csv")
+	output := new(strings.Builder)
+	csv.Extract(csv.NewContext(), []string{"Note"}, strings.NewReader(input), output)
+
+	if got, want := output.String(), "multi\nline, \"quoted\"\nplain\n"; got != want {
+		t.Errorf("Extract() = %q, want %q", got, want)
+	}
+}
+
+func TestUnclassified(t *testing.T) {
+	input := read(t, "users.csv")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^users\.(id|email)$`), Out: "mask"},
+		},
+		Unclassified: "abort",
+	}
+	ctx := csv.NewContext()
+	ctx.TableName = "users"
+	scrubber := scrubbing.NewScrubber("", false, policy, nil)
+	scrubber.Classifier = scrubbing.NewClassifier(policy)
+	scrubWith(ctx, input, scrubber)
+
+	var ue *scrubbing.UnclassifiedError
+	if err := scrubber.Classifier.Err(); !errors.As(err, &ue) {
+		t.Errorf("expected UnclassifiedError (got %v)", err)
+	} else if ue.Field != "users.Note" {
+		t.Errorf("unexpected unclassified field %q", ue.Field)
+	}
+
+	policy.FieldName = append(policy.FieldName, scrubbing.FieldNameRule{In: regexp.MustCompile(`^Note$`), Out: "pass"})
+	scrubber.Classifier = scrubbing.NewClassifier(policy)
+	scrubWith(ctx, input, scrubber)
+	if err := scrubber.Classifier.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestUnterminated(t *testing.T) {
+	ctx := csv.NewContext()
+	ctx.Header = false
+	reader := csv.NewReader(ctx, strings.NewReader("1,it\"s,\"a\"\"\n\"b\"\"\"x\n2,\"joe@foo.com\n3,bob@foo.com\n"))
+
+	record, err := reader.ReadRecord()
+	if err != nil {
+		t.Fatalf("ReadRecord() returned %v", err)
+	}
+	if want := "1,it\"s,\"a\"\"\n\"b\"\"\"x\n"; record != want {
+		t.Errorf("ReadRecord() = %q, want %q", record, want)
+	}
+
+	var ue *csv.UnterminatedError
+	if record, err = reader.ReadRecord(); !errors.As(err, &ue) {
+		t.Fatalf("expected UnterminatedError (got %q, %v)", record, err)
+	} else if ue.Line != 3 {
+		t.Errorf("unexpected line %d", ue.Line)
+	}
+}
@@ -0,0 +1,40 @@
+package json
+
+import (
+	"encoding/json"
+	"io"
+)
+
+func extract(names []string, data any) []string {
+	values := []string{}
+	walk(data, nil, func(s string, got []string) {
+		if matchFieldName(names, got) {
+			values = append(values, s)
+		}
+	})
+	return values
+}
+
+// Extract prints the value of every string field, in a stream of JSON
+// documents, whose name matches one of names; one value per line.
+func Extract(names []string, r io.Reader, w io.Writer) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var v any
+	for err := dec.Decode(&v); err == nil; err = dec.Decode(&v) {
+		for _, s := range extract(names, v) {
+			w.Write([]byte(s + "\n"))
+		}
+	}
+}
+
+func matchFieldName(want, got []string) bool {
+	for _, w := range want {
+		for _, g := range got {
+			if w == g {
+				return true
+			}
+		}
+	}
+	return false
+}
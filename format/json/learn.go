@@ -0,0 +1,49 @@
+package json
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/xeger/pipeclean/nlp"
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+func learn(models map[string]nlp.Model, policy *scrubbing.Policy, data any) {
+	walk(data, nil, func(s string, names []string) {
+		disposition, _ := policy.MatchFieldName(names)
+		disposition = policy.FirstStep(disposition)
+		switch disposition.Action() {
+		case "generate":
+			model := models[disposition.Parameter()]
+			if model != nil {
+				model.Train(s)
+			}
+		}
+	})
+}
+
+// Learn trains models using a stream of JSON documents.
+func Learn(models map[string]nlp.Model, policy *scrubbing.Policy, r io.Reader) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var v any
+	for err := dec.Decode(&v); err == nil; err = dec.Decode(&v) {
+		learn(models, policy, v)
+	}
+}
+
+// LearnChan trains models using newline-delimited JSON documents, one per
+// input string; blank or malformed lines are ignored. Models are not safe
+// for concurrent training, so the caller must not share them between
+// several LearnChan goroutines.
+func LearnChan(models map[string]nlp.Model, policy *scrubbing.Policy, in <-chan string) {
+	for line := range in {
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err == nil {
+			learn(models, policy, v)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package json_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/xeger/pipeclean/format/json"
+	"github.com/xeger/pipeclean/nlp"
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+const documents = `{"user":{"name":"Alice","email":"alice@example.com"},"tags":["a","b"]}
+{"user":{"name":"Bob"},"company":{"name":"Acme"}}`
+
+func TestLearn(t *testing.T) {
+	model := nlp.NewDictModel()
+	models := map[string]nlp.Model{"givenName": model}
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^user\.name$`), Out: "generate(givenName)"},
+		},
+	}
+	json.Learn(models, policy, strings.NewReader(documents))
+
+	for _, name := range []string{"Alice", "Bob"} {
+		if model.Recognize(name) != 1.0 {
+			t.Errorf("model was not trained with %q", name)
+		}
+	}
+	if model.Recognize("Acme") != 0.0 {
+		t.Errorf("model was trained with %q", "Acme")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	output := new(strings.Builder)
+	json.Extract([]string{"user.name", "tags[]"}, strings.NewReader(documents), output)
+
+	got := strings.Split(strings.TrimSpace(output.String()), "\n")
+	want := map[string]bool{"Alice": true, "a": true, "b": true, "Bob": true}
+	if len(got) != len(want) {
+		t.Errorf("Extract() = %q, want %d values", got, len(want))
+	}
+	for _, s := range got {
+		if !want[s] {
+			t.Errorf("Extract() produced unexpected value %q", s)
+		}
+	}
+}
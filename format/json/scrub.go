@@ -14,9 +14,11 @@ import (
 // only well-formed, complete documents to Scrub.
 func Scrub(sc *scrubbing.Scrubber, r io.Reader, w io.Writer) {
 	dec := json.NewDecoder(r)
+	dec.UseNumber()
 	enc := json.NewEncoder(w)
 	var v any
 	for err := dec.Decode(&v); err == nil; err = dec.Decode(&v) {
+		classify(sc, v, nil)
 		sc.ScrubData(v, nil)
 		enc.Encode(v)
 	}
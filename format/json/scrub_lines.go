@@ -0,0 +1,51 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+// Scrubs a line that holds one JSON document. Lines that are not exactly one
+// valid document cannot be scrubbed, so they are redacted (leaving only the
+// line terminator).
+func scrubLine(sc *scrubbing.Scrubber, line string) string {
+	doc := strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(doc) == "" {
+		return line
+	}
+
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber() // preserve numeric precision
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return line[len(doc):]
+	}
+	// a line that holds anything more than one document is just as
+	// malformed, and must not be passed through either
+	if _, err := dec.Token(); err != io.EOF {
+		return line[len(doc):]
+	}
+
+	classify(sc, v, nil)
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(sc.ScrubData(v, nil)); err != nil {
+		return line[len(doc):]
+	}
+	return strings.TrimRight(buf.String(), "\n") + line[len(doc):]
+}
+
+// ScrubChan sanitizes a sequence of newline-delimited JSON documents, one
+// per input string. It sends one output string for every input string
+// received, even for blank or malformed lines (which are emitted as blank
+// lines). This allows the caller to handle parallelism as desired.
+func ScrubChan(sc *scrubbing.Scrubber, in <-chan string, out chan<- string) {
+	for line := range in {
+		out <- scrubLine(sc, line)
+	}
+}
@@ -0,0 +1,43 @@
+package json_test
+
+import (
+	"testing"
+
+	"github.com/xeger/pipeclean/format/json"
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+func scrubLines(lines ...string) []string {
+	in := make(chan string)
+	out := make(chan string)
+	scrubber := scrubbing.NewScrubber("", false, scrubbing.DefaultPolicy(), nil)
+	go json.ScrubChan(scrubber, in, out)
+
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		in <- line
+		result = append(result, <-out)
+	}
+	close(in)
+	close(out)
+	return result
+}
+
+func TestScrubChan(t *testing.T) {
+	cases := map[string]string{
+		"{\"email\":\"joe@foo.com\"}\n":      "{\"email\":\"jyv@iws.com\"}\n",
+		"{\"n\":12345678901234567890}\n":     "{\"n\":12345678901234567890}\n",
+		"{\"html\":\"<a&b>\"}":               "{\"html\":\"<a&b>\"}",
+		"\n":                                 "\n",
+		"not json\n":                         "\n",
+		"{\"email\":\"joe@foo.com\"":         "",
+		"{} {\"email\":\"joe@foo.com\"}\n":   "\n",
+		"{\"email\":\"joe@foo.com\"}}\n":     "\n",
+		"[{\"phone\":\"805-555-1212\"}]\r\n": "[{\"phone\":\"606-245-3192\"}]\r\n",
+	}
+	for in, want := range cases {
+		if got := scrubLines(in)[0]; got != want {
+			t.Errorf("scrub(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package json
+
+import "github.com/xeger/pipeclean/scrubbing"
+
+// Walk calls visit for every string in a decoded JSON document, passing
+// the same field names that Scrubber.ScrubData would use for it.
+func walk(data any, names []string, visit func(s string, names []string)) {
+	switch v := data.(type) {
+	case string:
+		visit(v, names)
+	case []any:
+		elemNames := scrubbing.ElementNames(names)
+		for _, e := range v {
+			walk(e, elemNames, visit)
+		}
+	case map[string]any:
+		for k, e := range v {
+			walk(e, scrubbing.KeyNames(names, k), visit)
+		}
+	}
+}
+
+// Checks that every scrubbable value of a decoded JSON document belongs to
+// a classified field (see scrubbing.Classifier). Fields are reported by
+// their full path.
+func classify(sc *scrubbing.Scrubber, data any, names []string) {
+	switch v := data.(type) {
+	case nil, bool:
+	case []any:
+		elemNames := scrubbing.ElementNames(names)
+		for _, e := range v {
+			classify(sc, e, elemNames)
+		}
+	case map[string]any:
+		for k, e := range v {
+			classify(sc, e, scrubbing.KeyNames(names, k))
+		}
+	default:
+		if len(names) > 0 {
+			sc.Classifier.Check(names[len(names)-1], names)
+		}
+	}
+}
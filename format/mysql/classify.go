@@ -0,0 +1,38 @@
+package mysql
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+// Unclassified returns the names of all columns in TableColumns that do not
+// match any of the policy's field-name rules, in "table.column" notation.
+// Columns of tables that are subject to a table rule are disregarded.
+func (ctx *Context) Unclassified(policy *scrubbing.Policy) []string {
+	result := make([]string, 0)
+	for tableName, columnNames := range ctx.TableColumns {
+		if policy.MatchTable(tableName) != "" {
+			// the table's data is never output
+			continue
+		}
+		for colIdx, colName := range columnNames {
+			names := []string{colName, fmt.Sprintf("%s.%s", tableName, colName), fmt.Sprintf("%s.%d", tableName, colIdx)}
+			if disposition, _ := policy.MatchFieldName(names); disposition == "" {
+				result = append(result, names[1])
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Returns the name under which to report an unclassified column: its
+// "table.column" name if column names are known, or else "table.index".
+func classifiedName(names []string) string {
+	if len(names) == 3 {
+		return names[1]
+	}
+	return names[len(names)-1]
+}
@@ -0,0 +1,260 @@
+package mysql
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pingcap/tidb/parser/charset"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/parser/test_driver"
+	"github.com/pingcap/tidb/parser/types"
+	"github.com/xeger/pipeclean/rand"
+)
+
+// Upper bound (exclusive) on the digits that disambiguate values of unique
+// columns.
+const maxUniqueTag = 1000000
+
+// Column describes the declared type and constraints of a table column.
+type Column struct {
+	// Type is the declared data type, including length and flags.
+	Type *types.FieldType
+	// NotNull is true if the column may not contain NULL.
+	NotNull bool
+	// Unique is true if the column by itself forms a PRIMARY KEY or UNIQUE
+	// index. (Columns of multi-column indexes are not considered unique.)
+	Unique bool
+}
+
+// MaxLength returns the maximum length of string values in the column, and
+// whether that length is measured in bytes rather than characters. It
+// returns 0 for columns that do not hold strings.
+func (c *Column) MaxLength() (int, bool) {
+	switch c.Type.GetType() {
+	case mysql.TypeVarchar, mysql.TypeVarString, mysql.TypeString:
+		if c.Type.GetFlen() > 0 {
+			return c.Type.GetFlen(), c.binary()
+		}
+	case mysql.TypeTinyBlob:
+		return 1<<8 - 1, true
+	case mysql.TypeBlob:
+		return 1<<16 - 1, true
+	case mysql.TypeMediumBlob:
+		return 1<<24 - 1, true
+	}
+	return 0, false
+}
+
+// Reports whether string values of the column are compared byte-by-byte
+// (rather than with a case-insensitive, pad-space collation).
+func (c *Column) binary() bool {
+	return c.Type.GetCharset() == charset.CharsetBin
+}
+
+// Returns the range of values of an integer column, or nil if the column
+// does not hold integers.
+func (c *Column) intRange() (*big.Int, *big.Int) {
+	var bits uint
+	switch c.Type.GetType() {
+	case mysql.TypeTiny:
+		bits = 8
+	case mysql.TypeShort:
+		bits = 16
+	case mysql.TypeInt24:
+		bits = 24
+	case mysql.TypeLong:
+		bits = 32
+	case mysql.TypeLonglong:
+		bits = 64
+	default:
+		return nil, nil
+	}
+	if mysql.HasUnsignedFlag(c.Type.GetFlag()) {
+		max := new(big.Int).Lsh(big.NewInt(1), bits)
+		return big.NewInt(0), max.Sub(max, big.NewInt(1))
+	}
+	max := new(big.Int).Lsh(big.NewInt(1), bits-1)
+	min := new(big.Int).Neg(max)
+	return min, max.Sub(max, big.NewInt(1))
+}
+
+// Zero returns the value to write in place of NULL in a NOT NULL column.
+func (c *Column) zero() test_driver.Datum {
+	datum := test_driver.Datum{}
+	switch c.Type.GetType() {
+	case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong, mysql.TypeLonglong,
+		mysql.TypeFloat, mysql.TypeDouble, mysql.TypeNewDecimal, mysql.TypeBit:
+		datum.SetInt64(0)
+	case mysql.TypeYear:
+		datum.SetInt64(2000)
+	case mysql.TypeDate:
+		datum.SetString("2000-01-01")
+	case mysql.TypeDatetime, mysql.TypeTimestamp:
+		datum.SetString("2000-01-01 00:00:00")
+	case mysql.TypeDuration:
+		datum.SetString("00:00:00")
+	case mysql.TypeJSON:
+		datum.SetString("null")
+	case mysql.TypeEnum:
+		if elems := c.Type.GetElems(); len(elems) > 0 {
+			datum.SetString(elems[0])
+		} else {
+			datum.SetString("")
+		}
+	case mysql.TypeGeometry:
+		datum.SetNull()
+	default:
+		datum.SetString("")
+	}
+	return datum
+}
+
+// Fit adjusts a scrubbed value so that it fits the column: strings are
+// truncated to the column's length (email addresses by shortening the part
+// before the @) and integers are wrapped into its range.
+func (c *Column) fit(d test_driver.Datum) test_driver.Datum {
+	switch d.Kind() {
+	case test_driver.KindString:
+		if max, bytes := c.MaxLength(); max > 0 {
+			d.SetString(fitString(d.GetString(), max, bytes))
+		}
+	case test_driver.KindInt64, test_driver.KindUint64, test_driver.KindMysqlDecimal:
+		min, max := c.intRange()
+		if min == nil {
+			break
+		}
+		v, ok := datumInt(d)
+		if !ok || (v.Cmp(min) >= 0 && v.Cmp(max) <= 0) {
+			break
+		}
+		if v.Sign() >= 0 {
+			v.Rem(v, new(big.Int).Add(max, big.NewInt(1)))
+		} else {
+			v.Rem(v, new(big.Int).Sub(min, big.NewInt(1)))
+		}
+		d = numberDatum(v.String())
+	}
+	return d
+}
+
+// Returns the value of an integral datum.
+func datumInt(d test_driver.Datum) (*big.Int, bool) {
+	switch d.Kind() {
+	case test_driver.KindInt64:
+		return big.NewInt(d.GetInt64()), true
+	case test_driver.KindUint64:
+		return new(big.Int).SetUint64(d.GetUint64()), true
+	case test_driver.KindMysqlDecimal:
+		return new(big.Int).SetString(d.GetMysqlDecimal().String(), 10)
+	}
+	return nil, false
+}
+
+// Truncates s to at most max characters (or bytes). If s looks like an email
+// address and its domain fits, the local part is shortened instead so that
+// the result remains an address.
+func fitString(s string, max int, bytes bool) string {
+	if length(s, bytes) <= max {
+		return s
+	}
+	if at := strings.LastIndexByte(s, '@'); at > 0 {
+		if room := max - length(s[at:], bytes); room > 0 {
+			return truncate(s[:at], room, bytes) + s[at:]
+		}
+	}
+	return truncate(s, max, bytes)
+}
+
+// Returns the length of s in characters (or bytes).
+func length(s string, bytes bool) int {
+	if bytes {
+		return len(s)
+	}
+	return utf8.RuneCountInString(s)
+}
+
+// Truncates s to at most max characters (or bytes), never splitting a
+// multi-byte character.
+func truncate(s string, max int, bytes bool) string {
+	if bytes {
+		if len(s) <= max {
+			return s
+		}
+		for max > 0 && !utf8.RuneStart(s[max]) {
+			max--
+		}
+		return s[:max]
+	}
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	n := 0
+	for i := range s {
+		if n == max {
+			return s[:i]
+		}
+		n++
+	}
+	return s
+}
+
+// Returns the identity of a value with respect to a unique index, taking
+// the (typically case-insensitive, pad-space) collation into account.
+func (c *Column) uniqueKey(d test_driver.Datum) (string, bool) {
+	switch d.Kind() {
+	case test_driver.KindString:
+		s := d.GetString()
+		if !c.binary() {
+			s = strings.ToLower(strings.TrimRight(s, " "))
+		}
+		return s, true
+	case test_driver.KindInt64, test_driver.KindUint64, test_driver.KindMysqlDecimal:
+		if v, ok := datumInt(d); ok {
+			return v.String(), true
+		}
+	}
+	return "", false
+}
+
+// Derives the nth alternative to a value of a unique column from its
+// original value orig: the 0th is the value itself, while the others insert
+// digits derived from orig and n before the domain of an email address (or
+// at the end of other strings), or add them to an integer.
+func (c *Column) disambiguate(d test_driver.Datum, orig string, n int) (test_driver.Datum, bool) {
+	if n == 0 {
+		return d, true
+	}
+	tag := uint64(rand.Hash(orig+"\x00"+strconv.Itoa(n))) % maxUniqueTag
+	switch d.Kind() {
+	case test_driver.KindString:
+		s := d.GetString()
+		suffix := strconv.FormatUint(tag, 10)
+		at := strings.LastIndexByte(s, '@')
+		if at < 0 {
+			at = len(s)
+		}
+		head, tail := s[:at], s[at:]
+		if max, bytes := c.MaxLength(); max > 0 {
+			if max-length(tail, bytes) < 1 {
+				tail = ""
+			}
+			room := max - length(tail, bytes)
+			if room < 1 {
+				return d, false
+			}
+			if len(suffix) > room {
+				suffix = suffix[:room]
+			}
+			head = truncate(head, room-len(suffix), bytes)
+		}
+		d.SetString(head + suffix + tail)
+		return d, true
+	case test_driver.KindInt64, test_driver.KindUint64, test_driver.KindMysqlDecimal:
+		if v, ok := datumInt(d); ok {
+			return c.fit(numberDatum(v.Add(v, new(big.Int).SetUint64(tag+1)).String())), true
+		}
+	}
+	return d, false
+}
@@ -0,0 +1,72 @@
+package mysql
+
+import (
+	"github.com/pingcap/tidb/parser/test_driver"
+	"github.com/xeger/pipeclean/cmd/ui"
+	"github.com/xeger/pipeclean/rand"
+)
+
+// Number of alternatives to try before giving up on making a value unique.
+const maxUniqueAttempts = 1000
+
+// Adjusts a scrubbed value so that the output can be restored into a table
+// with the given column definition: NULLs are replaced by a zero value in NOT
+// NULL columns, strings are truncated to fit, integers are kept in range and
+// (if enforceUnique) values of unique columns are kept distinct. Orig is the
+// value before scrubbing.
+func (ctx *Context) constrain(field string, col *Column, orig string, d test_driver.Datum, enforceUnique bool) test_driver.Datum {
+	if col == nil {
+		return d
+	}
+	if d.Kind() == test_driver.KindNull {
+		if col.NotNull {
+			d = col.zero()
+		} else {
+			return d
+		}
+	}
+	d = col.fit(d)
+	if enforceUnique && col.Unique {
+		d = ctx.ensureUnique(field, col, orig, d)
+	}
+	return d
+}
+
+// Ensures that a value differs from every value previously emitted into the
+// same unique column.
+//
+// A value is adjusted only if it collides, so that values which do not
+// collide stay consistent with the same values in other columns (e.g. a
+// domain). The adjustment is derived from the original value, but which of
+// two colliding values is adjusted depends on the order in which they are
+// scrubbed. A 64-bit hash of every value is kept for the remainder of the
+// run.
+func (ctx *Context) ensureUnique(field string, col *Column, orig string, d test_driver.Datum) test_driver.Datum {
+	if _, ok := col.uniqueKey(d); !ok {
+		return d
+	}
+
+	ctx.mx.Lock()
+	defer ctx.mx.Unlock()
+	seen := ctx.unique[field]
+	if seen == nil {
+		seen = make(map[int64]bool)
+		ctx.unique[field] = seen
+	}
+
+	for n := 0; ; n++ {
+		candidate, ok := col.disambiguate(d, orig, n)
+		if !ok {
+			ui.Warnf("Cannot make value of unique field %q distinct; restore may fail", field)
+			return d
+		}
+		key, _ := col.uniqueKey(candidate)
+		if !seen[rand.Hash(key)] || n >= maxUniqueAttempts {
+			if n >= maxUniqueAttempts {
+				ui.Warnf("Cannot make value of unique field %q distinct; restore may fail", field)
+			}
+			seen[rand.Hash(key)] = true
+			return candidate
+		}
+	}
+}
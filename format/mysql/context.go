@@ -2,8 +2,11 @@ package mysql
 
 import (
 	"context"
+	"sync"
 
 	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/xeger/pipeclean/filtering"
 )
 
 // Context accumulates information about the structure of input data
@@ -11,6 +14,28 @@ import (
 type Context struct {
 	context.Context
 	TableColumns map[string][]string
+	// Columns records the type and constraints of each column, by table name
+	// and then column name.
+	Columns map[string]map[string]*Column
+	// PrimaryKeys records the primary key columns of each table, by table
+	// name.
+	PrimaryKeys map[string][]string
+	// ForeignKeys records the foreign keys of each table, by table name.
+	ForeignKeys map[string][]ForeignKey
+	// Filter, if not nil, decides which rows of INSERT statements to keep.
+	Filter *filtering.Policy
+	// Strict determines how scrubbing handles input that cannot be parsed.
+	Strict Strictness
+	cancel context.CancelCauseFunc
+
+	// Guards the schema (TableColumns, Columns, PrimaryKeys and ForeignKeys),
+	// which Reader may add to while statements are being scrubbed.
+	schema sync.RWMutex
+	mx     sync.Mutex
+	unique map[string]map[int64]bool
+	// Keys of the rows that subsetting retains, by table name and then by
+	// referenced columns (see Subset).
+	retained map[string]map[string]map[string]bool
 }
 
 func (sc *Context) Scan(sql string) error {
@@ -19,6 +44,8 @@ func (sc *Context) Scan(sql string) error {
 	if err != nil {
 		return err
 	}
+	sc.schema.Lock()
+	defer sc.schema.Unlock()
 	siv := &schemaInfoVisitor{info: sc}
 	for _, in := range stmts {
 		siv.ScanStatement(in)
@@ -26,9 +53,67 @@ func (sc *Context) Scan(sql string) error {
 	return nil
 }
 
+// Records the schema of each CREATE TABLE statement in sql whose table is
+// not yet known, i.e. was neither provided as context nor created earlier
+// in the same stream.
+func (sc *Context) learn(sql string) {
+	p := parser.New()
+	stmts, _, err := p.Parse(sql, "", "")
+	if err != nil {
+		return
+	}
+	sc.schema.Lock()
+	defer sc.schema.Unlock()
+	siv := &schemaInfoVisitor{info: sc}
+	for _, stmt := range stmts {
+		sc.learnStatement(siv, stmt)
+	}
+}
+
+// Records the schema of a CREATE TABLE statement unless its table is known.
+func (sc *Context) learnStatement(siv *schemaInfoVisitor, stmt ast.StmtNode) {
+	if create, ok := stmt.(*ast.CreateTableStmt); ok && len(sc.TableColumns[create.Table.Name.L]) == 0 {
+		siv.ScanStatement(stmt)
+	}
+}
+
+// Returns the column names of a table, if known.
+func (sc *Context) columns(tableName string) []string {
+	sc.schema.RLock()
+	defer sc.schema.RUnlock()
+	return sc.TableColumns[tableName]
+}
+
+// Returns the definition of a column, if known.
+func (sc *Context) column(tableName, colName string) *Column {
+	sc.schema.RLock()
+	defer sc.schema.RUnlock()
+	return sc.Columns[tableName][colName]
+}
+
+// Returns the primary key columns of a table, if known.
+func (sc *Context) primaryKey(tableName string) []string {
+	sc.schema.RLock()
+	defer sc.schema.RUnlock()
+	return sc.PrimaryKeys[tableName]
+}
+
+// Returns the foreign keys of a table, if known.
+func (sc *Context) foreignKeys(tableName string) []ForeignKey {
+	sc.schema.RLock()
+	defer sc.schema.RUnlock()
+	return sc.ForeignKeys[tableName]
+}
+
 func NewContext() *Context {
+	ctx, cancel := context.WithCancelCause(context.Background())
 	return &Context{
-		Context:      context.Background(),
+		Context:      ctx,
 		TableColumns: make(map[string][]string),
+		Columns:      make(map[string]map[string]*Column),
+		PrimaryKeys:  make(map[string][]string),
+		ForeignKeys:  make(map[string][]ForeignKey),
+		cancel:       cancel,
+		unique:       make(map[string]map[int64]bool),
 	}
 }
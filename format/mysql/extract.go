@@ -1,7 +1,6 @@
 package mysql
 
 import (
-	"bufio"
 	"io"
 
 	"github.com/pingcap/tidb/parser"
@@ -23,13 +22,13 @@ func Extract(ctx *Context, names []string, r io.Reader, w io.Writer) {
 	p := parser.New()
 	v := &extractVisitor{ctx, names, nil, nil}
 
-	br := bufio.NewReader(r)
+	reader := NewReader(ctx, r)
 	for {
-		line, err := br.ReadString('\n')
+		stmt, err := reader.ReadStatement()
 		if err != nil {
 			break
 		}
-		values := extract(v, p, line)
+		values := extract(v, p, stmt.Text)
 		for _, v := range values {
 			w.Write([]byte(v + "\n"))
 		}
@@ -0,0 +1,150 @@
+package mysql
+
+import (
+	"strings"
+
+	"github.com/pingcap/tidb/parser/ast"
+)
+
+// Returns the name of the table that an INSERT statement inserts into.
+func insertTableName(stmt *ast.InsertStmt) string {
+	if stmt.Table == nil || stmt.Table.TableRefs == nil {
+		return ""
+	}
+	if source, ok := stmt.Table.TableRefs.Left.(*ast.TableSource); ok {
+		if name, ok := source.Source.(*ast.TableName); ok {
+			return name.Name.L
+		}
+	}
+	return ""
+}
+
+// The value tuples of an INSERT statement, with enough information to look
+// up values by column name.
+type insertRows struct {
+	tableName string
+	// Index of each column in a tuple, by column name.
+	columns map[string]int
+	lists   [][]ast.ExprNode
+}
+
+func (ctx *Context) insertRows(stmt *ast.InsertStmt) *insertRows {
+	rows := &insertRows{tableName: insertTableName(stmt), columns: make(map[string]int), lists: stmt.Lists}
+	if len(stmt.Columns) > 0 {
+		for i, col := range stmt.Columns {
+			rows.columns[col.Name.L] = i
+		}
+	} else {
+		for i, col := range ctx.columns(rows.tableName) {
+			rows.columns[col] = i
+		}
+	}
+	return rows
+}
+
+// Returns a function that looks up values of the i'th tuple by column name.
+func (rows *insertRows) lookup(i int) func(string) (ast.ExprNode, bool) {
+	list := rows.lists[i]
+	return func(column string) (ast.ExprNode, bool) {
+		if idx, ok := rows.columns[column]; ok && idx < len(list) {
+			return list[idx], true
+		}
+		return nil, false
+	}
+}
+
+// Returns a string that identifies the values of some columns of the i'th
+// tuple, or false if any of them is NULL (or unknown).
+func (rows *insertRows) key(i int, columns []string) (string, bool) {
+	lookup := rows.lookup(i)
+	vals := make([]string, len(columns))
+	for j, col := range columns {
+		expr, ok := lookup(col)
+		if !ok {
+			return "", false
+		}
+		if vals[j], ok = exprString(expr); !ok {
+			return "", false
+		}
+	}
+	return strings.Join(vals, "\x00"), true
+}
+
+// Decides whether to keep the i'th tuple according to the filter for its
+// table, disregarding foreign keys.
+func (ctx *Context) keepOwn(rows *insertRows, i int) (bool, error) {
+	table := ctx.Filter.Table(rows.tableName)
+	if table == nil {
+		return true, nil
+	}
+	lookup := rows.lookup(i)
+	primaryKey := ctx.primaryKey(rows.tableName)
+	if len(primaryKey) == 0 {
+		for name, idx := range rows.columns {
+			if idx == 0 {
+				primaryKey = []string{name}
+			}
+		}
+	}
+	if len(primaryKey) == 0 {
+		// Neither the schema nor the statement names the columns; identify
+		// the row by its first value, under its positional name.
+		first := rows.tableName + ".0"
+		primaryKey = []string{first}
+		named := lookup
+		lookup = func(column string) (ast.ExprNode, bool) {
+			if column == first && len(rows.lists[i]) > 0 {
+				return rows.lists[i][0], true
+			}
+			return named(column)
+		}
+	}
+	return table.Keep(rows.tableName, lookup, primaryKey)
+}
+
+// Reports whether every foreign key of the i'th tuple refers to a row that
+// subsetting retains. Foreign keys that are NULL, or that refer to tables
+// with no rows in the input, are disregarded.
+func (ctx *Context) keepReferences(rows *insertRows, i int) bool {
+	for _, fk := range ctx.foreignKeys(rows.tableName) {
+		retained := ctx.retained[fk.Table]
+		if retained == nil {
+			continue
+		}
+		if key, ok := rows.key(i, fk.Columns); ok && !retained[fk.refKey()][key] {
+			return false
+		}
+	}
+	return true
+}
+
+// Removes the value tuples of an INSERT statement that ctx.Filter does not
+// keep, or that refer to rows that subsetting does not retain. Returns false
+// if no tuples remain, i.e. the statement should be omitted from output.
+//
+// If a filter cannot be evaluated (e.g. it refers to an unknown column), ctx
+// is canceled and all tuples are kept.
+func (ctx *Context) filter(stmt *ast.InsertStmt) bool {
+	if len(stmt.Lists) == 0 || ctx.Filter == nil {
+		return true
+	}
+	tableName := insertTableName(stmt)
+	if ctx.Filter.Table(tableName) == nil && (ctx.retained == nil || len(ctx.foreignKeys(tableName)) == 0) {
+		return true
+	}
+
+	rows := ctx.insertRows(stmt)
+	lists := make([][]ast.ExprNode, 0, len(stmt.Lists))
+	for i, list := range stmt.Lists {
+		keep, err := ctx.keepOwn(rows, i)
+		if err != nil {
+			ctx.cancel(err)
+			return true
+		}
+		if keep && (ctx.retained == nil || ctx.keepReferences(rows, i)) {
+			lists = append(lists, list)
+		}
+	}
+	stmt.Lists = lists
+	return len(lists) > 0
+}
@@ -44,6 +44,7 @@ func (v *learnVisitor) Enter(in ast.Node) (ast.Node, bool) {
 			switch typed.Kind() {
 			case test_driver.KindString:
 				disposition, _ := v.policy.MatchFieldName(v.insert.Names())
+				disposition = v.policy.FirstStep(disposition)
 				switch disposition.Action() {
 				case "generate":
 					model := v.models[disposition.Parameter()]
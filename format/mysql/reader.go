@@ -0,0 +1,185 @@
+package mysql
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Upper bound on the size of a statement; if a statement grows beyond
+// this size without being terminated, Reader returns it anyway (marked as
+// Unterminated) so that memory usage remains bounded.
+const maxStatementLength = 64 * 1024 * 1024
+
+// Matches a line that begins a statement, as mysqldump writes them. A
+// string literal that is still open when such a line is reached is taken to
+// be unterminated (e.g. because of a stray quote) rather than to span lines.
+var reStatementStart = regexp.MustCompile(`^(INSERT INTO|REPLACE INTO|CREATE TABLE|DROP TABLE|ALTER TABLE|LOCK TABLES|UNLOCK TABLES|SET |DELIMITER |/\*![0-9])`)
+
+// Matches a client-side DELIMITER command.
+var reDelimiter = regexp.MustCompile(`(?i)^\s*DELIMITER\s+(\S+)`)
+
+// Matches the beginning of a statement that refers to a table: CREATE
+// TABLE, LOCK TABLES, INSERT or REPLACE. Captures "CREATE" (if the table is
+// being created) and the (unqualified, possibly quoted) name of the table.
+var reTableStatement = regexp.MustCompile(`(?i)^\s*(?:(CREATE)\s+(?:TEMPORARY\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?|LOCK\s+TABLES?\s+|(?:INSERT|REPLACE)\s+(?:(?:LOW_PRIORITY|DELAYED|HIGH_PRIORITY|IGNORE)\s+)*(?:INTO\s+)?)` +
+	"(?:(?:`[^`]*`|\\w+)\\.)?(`[^`]*`|\\w+)")
+
+// Reader splits a mysqldump stream into statements. Usually, each line is a
+// statement (or several); however, hand-edited fixtures, dumps made with
+// --skip-extended-insert and stored routines may contain statements that
+// span several lines. Reader joins such lines so that every string it
+// returns is complete; it understands string literals, comments and the
+// client-side DELIMITER command. Input that is cut short by a quote or
+// comment that is never closed is returned as an Unterminated statement.
+//
+// Reader also records the schema of each CREATE TABLE statement in ctx
+// (unless the table is already known), so that later INSERT statements of
+// the same stream can be understood even though they omit column names.
+// Because of this, the stream must be read sequentially; however, the
+// resulting statements may be processed in parallel.
+//
+// Reader also marks the first statement of the stream that refers to each
+// table (see Statement.Table).
+type Reader struct {
+	ctx       *Context
+	seen      map[string]bool
+	br        *bufio.Reader
+	delimiter string
+	line      int
+	// A line that has been read, but belongs to the next statement.
+	next string
+}
+
+// Statement is a complete SQL statement (or several) read from input, or a
+// run of comments and whitespace.
+type Statement struct {
+	// Line is the (1-based) number of the input line where the statement begins.
+	Line int
+	// Text is the content of the statement, including its line terminator.
+	Text string
+	// Table is the name of a table if this is the first statement of input
+	// that creates it, locks it or inserts into it; otherwise it is empty.
+	Table string
+	// Unterminated is true if Text ends inside a string literal or comment,
+	// or inside a statement that exceeds the maximum length.
+	Unterminated bool
+}
+
+// NewReader returns a Reader of r. If ctx is nil, no schema is recorded.
+func NewReader(ctx *Context, r io.Reader) *Reader {
+	return &Reader{ctx: ctx, seen: make(map[string]bool), br: bufio.NewReader(r), delimiter: ";"}
+}
+
+// ReadStatement returns the next statement(s) of input. Lines that contain
+// only comments or whitespace are returned as-is. It returns io.EOF after
+// the final statement has been read.
+func (r *Reader) ReadStatement() (Statement, error) {
+	stmt, err := r.readStatement()
+	if err != nil {
+		return stmt, err
+	}
+	if m := reTableStatement.FindStringSubmatch(stmt.Text); m != nil {
+		if r.ctx != nil && m[1] != "" {
+			r.ctx.learn(stmt.Text)
+		}
+		name := strings.ToLower(strings.Trim(m[2], "`"))
+		if !r.seen[name] {
+			r.seen[name] = true
+			stmt.Table = name
+		}
+	}
+	return stmt, nil
+}
+
+func (r *Reader) readStatement() (Statement, error) {
+	var sb strings.Builder
+	start := r.line + 1
+	var quote byte
+	blockComment := false
+	// True if non-comment content has been seen since the last delimiter.
+	pending := false
+	stmt := func() (Statement, error) {
+		return Statement{Line: start, Text: sb.String(), Unterminated: quote != 0 || blockComment}, nil
+	}
+
+	for {
+		var line string
+		var err error
+		if r.next != "" {
+			line, r.next = r.next, ""
+		} else {
+			line, err = r.br.ReadString('\n')
+		}
+		if err != nil && (err != io.EOF || line == "") {
+			if sb.Len() > 0 {
+				return stmt()
+			}
+			return Statement{}, err
+		}
+		if quote != 0 && reStatementStart.MatchString(line) {
+			r.next = line
+			return stmt()
+		}
+		r.line++
+
+		if !pending && quote == 0 && !blockComment {
+			if m := reDelimiter.FindStringSubmatch(line); m != nil {
+				r.delimiter = m[1]
+				sb.WriteString(line)
+				return stmt()
+			}
+		}
+		sb.WriteString(line)
+
+	scan:
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case blockComment:
+				if c == '*' && i+1 < len(line) && line[i+1] == '/' {
+					blockComment = false
+					i++
+				}
+			case quote != 0:
+				if c == '\\' && quote != '`' {
+					i++
+				} else if c == quote {
+					if i+1 < len(line) && line[i+1] == quote {
+						i++
+					} else {
+						quote = 0
+					}
+				}
+			case strings.HasPrefix(line[i:], r.delimiter):
+				pending = false
+				i += len(r.delimiter) - 1
+			case c == '\'' || c == '"' || c == '`':
+				quote = c
+				pending = true
+			case c == '/' && i+1 < len(line) && line[i+1] == '*':
+				blockComment = true
+				// Executable comments (/*! ... */) and optimizer hints are content.
+				if i+2 < len(line) && (line[i+2] == '!' || line[i+2] == '+') {
+					pending = true
+				}
+				i++
+			case c == '#' || (c == '-' && strings.HasPrefix(line[i:], "-- ")) || strings.TrimRight(line[i:], "\r\n") == "--":
+				break scan
+			case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			default:
+				pending = true
+			}
+		}
+
+		if (!pending && quote == 0 && !blockComment) || err == io.EOF {
+			return stmt()
+		}
+		if sb.Len() > maxStatementLength {
+			s, _ := stmt()
+			s.Unterminated = true
+			return s, nil
+		}
+	}
+}
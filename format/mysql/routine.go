@@ -0,0 +1,155 @@
+package mysql
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+)
+
+// Matches the beginning of a stored routine definition, which the parser
+// does not understand, including mysqldump's executable-comment form
+// (/*!50003 CREATE*/ /*!50020 DEFINER=...*/ /*!50003 TRIGGER ...).
+var reRoutine = regexp.MustCompile(`(?is)^\s*(/\*!\d*\s*)?CREATE\b.*?\b(PROCEDURE|FUNCTION|TRIGGER|EVENT)\b`)
+
+// Matches the event clause of a trigger (e.g. "AFTER INSERT ON `users`").
+var reTriggerEvent = regexp.MustCompile(`(?is)^insert\s+on\b`)
+
+// Scrubs the INSERT statements in the body of a stored routine, leaving the
+// rest of its text intact. Each candidate runs from the keyword INSERT to the
+// next semicolon (or to the end of the executable comment that contains it,
+// as in the triggers of mysqldump); candidates that do not parse are left alone. Returns false
+// if any candidate other than the event clause of a trigger did not parse.
+func scrubRoutine(sv *scrubVisitor, p *parser.Parser, text string) (string, bool) {
+	var sb strings.Builder
+	last := 0
+	complete := true
+	sc := &routineScanner{s: text}
+	for {
+		start, ok := sc.next()
+		if !ok {
+			break
+		}
+		if !isKeyword(text, start, "insert") {
+			continue
+		}
+		if reTriggerEvent.MatchString(text[start:]) {
+			continue
+		}
+		end := sc.clone().end()
+		if end < 0 {
+			complete = false
+			break
+		}
+		stmts, _, err := p.Parse(text[start:end], "", "")
+		if err != nil || len(stmts) != 1 {
+			complete = false
+			continue
+		}
+		ins, ok := stmts[0].(*ast.InsertStmt)
+		if !ok {
+			complete = false
+			continue
+		}
+		sv.insert = newInsertState(ins)
+		ins.Accept(sv)
+		sv.insert = nil
+		sb.WriteString(text[last:start])
+		if text[end] == ';' {
+			sb.WriteString(strings.TrimSuffix(restore(ins), "\n"))
+			last = end + 1
+		} else {
+			sb.WriteString(strings.TrimSuffix(restore(ins), ";\n"))
+			last = end
+		}
+		sc.pos = last
+	}
+	sb.WriteString(text[last:])
+	return sb.String(), complete
+}
+
+// Reports whether the identifier at text[i:] is the given keyword.
+func isKeyword(text string, i int, keyword string) bool {
+	if i > 0 && isIdentByte(text[i-1]) {
+		return false
+	}
+	j := i + len(keyword)
+	if j > len(text) || !strings.EqualFold(text[i:j], keyword) {
+		return false
+	}
+	return j == len(text) || !isIdentByte(text[j])
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// Visits the bytes of SQL text that are outside of string literals, quoted
+// identifiers and comments. Executable comments (/*! ... */) are content.
+type routineScanner struct {
+	s   string
+	pos int
+}
+
+func (r *routineScanner) clone() *routineScanner {
+	c := *r
+	return &c
+}
+
+// Returns the offset of the next byte of content, or false at the end.
+func (r *routineScanner) next() (int, bool) {
+	for r.pos < len(r.s) {
+		i, c := r.pos, r.s[r.pos]
+		r.pos++
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			r.skipQuoted(c)
+		case c == '#' || strings.HasPrefix(r.s[i:], "-- "):
+			if nl := strings.IndexByte(r.s[i:], '\n'); nl >= 0 {
+				r.pos = i + nl + 1
+			} else {
+				r.pos = len(r.s)
+			}
+		case strings.HasPrefix(r.s[i:], "/*") && !strings.HasPrefix(r.s[i:], "/*!"):
+			if end := strings.Index(r.s[i+2:], "*/"); end >= 0 {
+				r.pos = i + 2 + end + 2
+			} else {
+				r.pos = len(r.s)
+			}
+		default:
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Returns the offset of the next content ";", or of the "*/" that closes an
+// executable comment, or -1 if there is neither.
+func (r *routineScanner) end() int {
+	for {
+		i, ok := r.next()
+		if !ok {
+			return -1
+		}
+		if r.s[i] == ';' || strings.HasPrefix(r.s[i:], "*/") {
+			return i
+		}
+	}
+}
+
+func (r *routineScanner) skipQuoted(quote byte) {
+	for r.pos < len(r.s) {
+		c := r.s[r.pos]
+		r.pos++
+		if c == '\\' && quote != '`' {
+			r.pos++
+		} else if c == quote {
+			if r.pos < len(r.s) && r.s[r.pos] == quote {
+				r.pos++
+			} else {
+				return
+			}
+		}
+	}
+}
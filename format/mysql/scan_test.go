@@ -2,9 +2,11 @@ package mysql_test
 
 import (
 	"reflect"
+	"regexp"
 	"testing"
 
 	"github.com/xeger/pipeclean/format/mysql"
+	"github.com/xeger/pipeclean/scrubbing"
 )
 
 func scan(input string) *mysql.Context {
@@ -24,3 +26,40 @@ func TestScanCreateTables(t *testing.T) {
 		t.Errorf("TableColumns scan failed: expected %v, got %v", expected, ctx.TableColumns)
 	}
 }
+
+func TestScanUnclassified(t *testing.T) {
+	input := read(t, "create_tables.sql")
+	ctx := scan(input)
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`_at$`), Out: "pass"},
+			{In: regexp.MustCompile(`^ar_internal_metadata\.key$`), Out: "pass"},
+		},
+	}
+
+	expected := []string{"ar_internal_metadata.value"}
+	if got := ctx.Unclassified(policy); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unclassified: expected %v, got %v", expected, got)
+	}
+}
+
+func TestScanColumns(t *testing.T) {
+	input := read(t, "insert-constrained.sql")
+	ctx := scan(input)
+
+	columns := ctx.Columns["users"]
+	if columns == nil {
+		t.Fatalf("Columns scan failed: no columns for users")
+	}
+	if col := columns["id"]; col == nil || !col.NotNull || !col.Unique {
+		t.Errorf("id should be NOT NULL and unique")
+	}
+	if col := columns["email"]; col == nil || !col.NotNull || !col.Unique {
+		t.Errorf("email should be NOT NULL and unique")
+	} else if max, bytes := col.MaxLength(); max != 16 || bytes {
+		t.Errorf("email MaxLength: expected 16 characters, got %d (bytes=%v)", max, bytes)
+	}
+	if col := columns["nickname"]; col == nil || col.NotNull || col.Unique {
+		t.Errorf("nickname should be nullable and not unique")
+	}
+}
@@ -11,13 +11,71 @@ type schemaInfoVisitor struct {
 }
 
 func (v *schemaInfoVisitor) ScanStatement(stmt ast.StmtNode) {
-	switch stmt.(type) {
+	switch typed := stmt.(type) {
 	case *ast.CreateTableStmt:
 		v.tableName = ""
 		stmt.Accept(v)
+		v.scanColumns(typed)
 	}
 }
 
+// Records the type and constraints of each column of a table.
+func (v *schemaInfoVisitor) scanColumns(stmt *ast.CreateTableStmt) {
+	columns := make(map[string]*Column, len(stmt.Cols))
+	for _, def := range stmt.Cols {
+		col := &Column{Type: def.Tp}
+		for _, opt := range def.Options {
+			switch opt.Tp {
+			case ast.ColumnOptionNotNull:
+				col.NotNull = true
+			case ast.ColumnOptionPrimaryKey:
+				col.NotNull = true
+				col.Unique = true
+				v.info.PrimaryKeys[stmt.Table.Name.L] = []string{def.Name.Name.L}
+			case ast.ColumnOptionUniqKey:
+				col.Unique = true
+			}
+		}
+		columns[def.Name.Name.L] = col
+	}
+	for _, cons := range stmt.Constraints {
+		if cons.Tp == ast.ConstraintForeignKey && cons.Refer != nil && cons.Refer.Table != nil {
+			fk := ForeignKey{Table: cons.Refer.Table.Name.L}
+			for _, k := range cons.Keys {
+				if k.Column != nil {
+					fk.Columns = append(fk.Columns, k.Column.Name.L)
+				}
+			}
+			for _, k := range cons.Refer.IndexPartSpecifications {
+				if k.Column != nil {
+					fk.RefColumns = append(fk.RefColumns, k.Column.Name.L)
+				}
+			}
+			if len(fk.Columns) > 0 && len(fk.Columns) == len(fk.RefColumns) {
+				v.info.ForeignKeys[stmt.Table.Name.L] = append(v.info.ForeignKeys[stmt.Table.Name.L], fk)
+			}
+		}
+		if cons.Tp == ast.ConstraintPrimaryKey {
+			key := make([]string, 0, len(cons.Keys))
+			for _, k := range cons.Keys {
+				if k.Column != nil {
+					key = append(key, k.Column.Name.L)
+				}
+			}
+			v.info.PrimaryKeys[stmt.Table.Name.L] = key
+		}
+		switch cons.Tp {
+		case ast.ConstraintPrimaryKey, ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex:
+			if len(cons.Keys) == 1 && cons.Keys[0].Column != nil {
+				if col := columns[cons.Keys[0].Column.Name.L]; col != nil {
+					col.Unique = true
+				}
+			}
+		}
+	}
+	v.info.Columns[stmt.Table.Name.L] = columns
+}
+
 func (v *schemaInfoVisitor) Enter(in ast.Node) (ast.Node, bool) {
 	switch typed := in.(type) {
 	case *ast.TableName:
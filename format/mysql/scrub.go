@@ -8,15 +8,28 @@ import (
 	"github.com/xeger/pipeclean/scrubbing"
 )
 
-func scrub(sv *scrubVisitor, p *parser.Parser, line string) string {
+func scrub(sv *scrubVisitor, p *parser.Parser, stmt Statement) string {
 	buf := bytes.NewBufferString("")
+	if stmt.Unterminated {
+		return sv.ctx.reject(stmt)
+	}
 
-	stmts, _, err := p.Parse(line, "", "")
+	stmts, _, err := p.Parse(stmt.Text, "", "")
 	if (err != nil || len(stmts) == 0) && doComments {
-		fmt.Fprint(buf, line)
+		if !reRoutine.MatchString(stmt.Text) {
+			fmt.Fprint(buf, sv.ctx.reject(stmt))
+		} else if text, ok := scrubRoutine(sv, p, stmt.Text); ok || sv.ctx.Strict == Lenient {
+			fmt.Fprint(buf, text)
+		} else {
+			// an INSERT of the routine's body may leak
+			fmt.Fprint(buf, sv.ctx.reject(stmt))
+		}
 	}
 
 	for _, in := range stmts {
+		if doInserts && tableData(sv.scrubber.Policy(), in) {
+			continue
+		}
 		out, processed := sv.ScrubStatement(in)
 		if !processed {
 			fmt.Fprintln(buf, out.OriginalText())
@@ -24,18 +37,33 @@ func scrub(sv *scrubVisitor, p *parser.Parser, line string) string {
 			fmt.Fprintln(buf, restore(out))
 		}
 	}
+	if doInserts && stmt.Table != "" {
+		fmt.Fprint(buf, sv.ctx.fixtures(sv.scrubber.Policy(), stmt.Table))
+	}
+	if err := sv.scrubber.Classifier.Err(); err != nil {
+		sv.ctx.cancel(err)
+	}
+	if err := sv.scrubber.Err(); err != nil {
+		sv.ctx.cancel(err)
+	}
 
 	return buf.String()
 }
 
-// ScrubChan sanitizes a sequence of lines, each of which may contain multiple
-// SQL statements. It sends one output string for every input string received,
-// even for multi-line or multi-statement inputs. This allows the caller to
-// handle parallelism as desired.
-func ScrubChan(ctx *Context, sc *scrubbing.Scrubber, in <-chan string, out chan<- string) {
-	sv := &scrubVisitor{ctx, sc, nil}
+// ScrubChan sanitizes a sequence of statements produced by a Reader, each of
+// which may contain multiple SQL statements. It sends one output string for
+// every input received, even for multi-line or multi-statement inputs. This
+// allows the caller to handle parallelism as desired.
+//
+// Input that cannot be parsed is handled according to ctx.Strict; if it
+// causes ctx to be canceled, the caller should stop scrubbing and report
+// context.Cause(ctx). Likewise, ctx is canceled if sc fails (see
+// Scrubber.Err) or if its Classifier sees an unclassified field that the
+// policy does not allow.
+func ScrubChan(ctx *Context, sc *scrubbing.Scrubber, in <-chan Statement, out chan<- string) {
+	sv := &scrubVisitor{ctx: ctx, scrubber: sc, usesRows: sc.Policy().UsesRows()}
 	p := parser.New()
-	for line := range in {
-		out <- scrub(sv, p, line)
+	for stmt := range in {
+		out <- scrub(sv, p, stmt)
 	}
 }
@@ -3,11 +3,21 @@ package mysql_test
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"os"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/xeger/pipeclean/filtering"
 	"github.com/xeger/pipeclean/format/mysql"
+	"github.com/xeger/pipeclean/fpe"
 	"github.com/xeger/pipeclean/scrubbing"
 )
 
@@ -26,22 +36,23 @@ func scrub(ctx *mysql.Context, input string) string {
 }
 
 func scrubPolicy(ctx *mysql.Context, input string, policy *scrubbing.Policy) string {
-	reader := bufio.NewReader(bytes.NewBufferString(input))
-	in := make(chan string)
+	reader := mysql.NewReader(ctx, bytes.NewBufferString(input))
+	in := make(chan mysql.Statement)
 
 	out := make(chan string)
 	output := bytes.NewBuffer(make([]byte, 0, len(input)))
 	writer := bufio.NewWriter(output)
 
 	scrubber := scrubbing.NewScrubber("", false, policy, nil)
+	scrubber.Classifier = scrubbing.NewClassifier(policy)
 	go mysql.ScrubChan(ctx, scrubber, in, out)
 
 	for {
-		line, err := reader.ReadString('\n')
+		stmt, err := reader.ReadStatement()
 		if err != nil {
 			break
 		}
-		in <- line
+		in <- stmt
 		scrubbed := <-out
 		writer.WriteString(scrubbed)
 	}
@@ -103,6 +114,23 @@ func TestInsertPositional(t *testing.T) {
 	}
 }
 
+func TestInsertDefaultPolicy(t *testing.T) {
+	for input, exp := range map[string]string{
+		"INSERT INTO phone_numbers (id, number) VALUES (1,'805-555-1212');":   "VALUES (1,'",
+		"INSERT INTO email_addresses (id, address) VALUES (1,'joe@foo.com');": "VALUES (1,'",
+	} {
+		output := scrub(mysql.NewContext(), input+"\n")
+		if !strings.Contains(output, exp) {
+			t.Errorf("id was masked: %s", output)
+		}
+		for _, secret := range []string{"805-555-1212", "joe@foo.com"} {
+			if strings.Contains(output, secret) {
+				t.Errorf("%s was not masked: %s", secret, output)
+			}
+		}
+	}
+}
+
 func TestInsertPositionalNoScan(t *testing.T) {
 	input := read(t, "insert-positional.sql")
 
@@ -110,3 +138,575 @@ func TestInsertPositionalNoScan(t *testing.T) {
 	// output may not be useful, but it shouldn't crash if there are no column names to work with!
 	scrub(ctx, input)
 }
+
+func TestInsertMultiline(t *testing.T) {
+	input := read(t, "insert-multiline.sql")
+	output := scrub(mysql.NewContext(), input)
+
+	if strings.Index(output, "INSERT INTO `users` (`id`,`email`,`bio`) VALUES (1,'jyv@iws.com','line one;\\nline two -- not a comment');") < 0 {
+		t.Errorf("multi-line INSERT statement not properly sanitized")
+	}
+	if strings.Index(output, "/* a comment; that spans\n   several lines */\n") < 0 {
+		t.Errorf("multi-line comment is missing")
+	}
+	if strings.Index(output, "INSERT INTO `users` (`id`,`email`,`bio`) VALUES (2,'hruhlic@mzovvt.com','it''s \"quoted\"');") < 0 {
+		t.Errorf("INSERT statement not properly sanitized")
+	}
+	if strings.Index(output, "DELIMITER ;;\nCREATE PROCEDURE `p`()\nBEGIN\n  INSERT INTO `users` (`id`,`email`) VALUES (3,'") < 0 {
+		t.Errorf("stored procedure is missing")
+	}
+	if strings.Index(output, "');\nEND ;;\nDELIMITER ;\n") < 0 {
+		t.Errorf("stored procedure is incomplete")
+	}
+	if strings.Index(output, "proc@foo.com") >= 0 {
+		t.Errorf("INSERT in stored procedure not sanitized")
+	}
+}
+
+func TestStrictLenient(t *testing.T) {
+	input := read(t, "unparseable.sql")
+	output := scrub(mysql.NewContext(), input)
+
+	if strings.Index(output, "'leak@foo.com'") < 0 {
+		t.Errorf("unparseable input was not passed through")
+	}
+}
+
+func TestStrictRedact(t *testing.T) {
+	input := read(t, "unparseable.sql")
+	ctx := mysql.NewContext()
+	ctx.Strict = mysql.Redact
+	output := scrub(ctx, input)
+
+	for _, want := range []string{"-- MySQL dump 10.13\n", "/*!40101 SET NAMES utf8mb4 */;\n", "# a shell-style comment\n", "'jyv@iws.com'", mysql.RedactedMarker + "\n"} {
+		if strings.Index(output, want) < 0 {
+			t.Errorf("output is missing %q", want)
+		}
+	}
+	if strings.Index(output, "leak") >= 0 {
+		t.Errorf("unparseable input was not redacted")
+	}
+	if ctx.Err() != nil {
+		t.Errorf("context was unexpectedly canceled")
+	}
+}
+
+func TestStrictAbort(t *testing.T) {
+	input := read(t, "unparseable.sql")
+	ctx := mysql.NewContext()
+	ctx.Strict = mysql.Abort
+	output := scrub(ctx, input)
+
+	if strings.Index(output, "leak") >= 0 {
+		t.Errorf("unparseable input was not suppressed")
+	}
+	var ue *mysql.UnparseableError
+	if err := context.Cause(ctx); !errors.As(err, &ue) {
+		t.Errorf("context was not canceled with UnparseableError (got %v)", err)
+	} else if ue.Line != 5 || ue.Snippet != "INSERT INTO `users` (`id`, `email`) VALUES (2, 'leak@foo.com'" {
+		t.Errorf("unexpected error details: %v", ue)
+	}
+}
+
+func TestStrictRoutine(t *testing.T) {
+	input := read(t, "insert-trigger.sql")
+	for _, strict := range []mysql.Strictness{mysql.Lenient, mysql.Redact, mysql.Abort} {
+		ctx := mysql.NewContext()
+		ctx.Strict = strict
+		output := scrub(ctx, input)
+
+		if !strings.Contains(output, "/*!50003 TRIGGER `users_audit` AFTER INSERT ON `users` FOR EACH ROW INSERT INTO `audit` (`id`,`email`) VALUES (1,'") {
+			t.Errorf("strict=%d: trigger is missing: %s", strict, output)
+		}
+		if strings.Contains(output, "proc@foo.com") {
+			t.Errorf("strict=%d: INSERT in trigger not sanitized: %s", strict, output)
+		}
+		if !strings.Contains(output, "/*!40101 SET NAMES utf8mb4 */;") {
+			t.Errorf("strict=%d: executable comment is missing: %s", strict, output)
+		}
+		if strict != mysql.Lenient && strings.Contains(output, "hidden@foo.com") {
+			t.Errorf("strict=%d: unparseable executable comment was emitted: %s", strict, output)
+		}
+	}
+
+	ctx := mysql.NewContext()
+	ctx.Strict = mysql.Abort
+	scrub(ctx, input)
+	var ue *mysql.UnparseableError
+	if err := context.Cause(ctx); !errors.As(err, &ue) || ue.Line != 6 {
+		t.Errorf("context was not canceled with UnparseableError at line 6 (got %v)", err)
+	}
+}
+
+func TestStrictUnterminated(t *testing.T) {
+	input := read(t, "unterminated.sql")
+	for _, strict := range []mysql.Strictness{mysql.Lenient, mysql.Redact} {
+		ctx := mysql.NewContext()
+		ctx.Strict = strict
+		output := scrub(ctx, input)
+
+		for _, secret := range []string{"broken", "two@foo.com", "three@foo.com", "four@foo.com"} {
+			if strings.Contains(output, secret) {
+				t.Errorf("strict=%d: %s was not scrubbed: %s", strict, secret, output)
+			}
+		}
+		if n := strings.Count(output, "INSERT INTO `users`"); n != 3 {
+			t.Errorf("strict=%d: %d INSERTs follow the stray quote, want 3: %s", strict, n, output)
+		}
+		if !strings.HasPrefix(output, mysql.RedactedMarker+"\n") {
+			t.Errorf("strict=%d: unterminated statement was not redacted: %s", strict, output)
+		}
+	}
+
+	ctx := mysql.NewContext()
+	ctx.Strict = mysql.Abort
+	scrub(ctx, input)
+	var ue *mysql.UnparseableError
+	if err := context.Cause(ctx); !errors.As(err, &ue) || ue.Line != 1 {
+		t.Errorf("context was not canceled with UnparseableError at line 1 (got %v)", err)
+	}
+}
+
+func TestUnclassified(t *testing.T) {
+	input := read(t, "insert-named.sql")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`routing_number`), Out: "mask"},
+		},
+		Unclassified: "abort",
+	}
+	ctx := mysql.NewContext()
+	scrubPolicy(ctx, input, policy)
+
+	var ue *scrubbing.UnclassifiedError
+	if err := context.Cause(ctx); !errors.As(err, &ue) {
+		t.Errorf("context was not canceled with UnclassifiedError (got %v)", err)
+	} else if ue.Field != "bank_accounts.id" {
+		t.Errorf("unexpected unclassified field %q", ue.Field)
+	}
+
+	policy.FieldName = append(policy.FieldName, scrubbing.FieldNameRule{In: regexp.MustCompile(`^bank_accounts\.id$`), Out: "pass"})
+	ctx = mysql.NewContext()
+	scrubPolicy(ctx, input, policy)
+	if err := context.Cause(ctx); err != nil {
+		t.Errorf("context was unexpectedly canceled: %v", err)
+	}
+}
+
+func TestInsertTyped(t *testing.T) {
+	input := read(t, "insert-typed.sql")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^people\.(birth_year|salary|height|flags)$`), Out: "mask"},
+			{In: regexp.MustCompile(`^people\.born_on$`), Out: "shift(365)"},
+			{In: regexp.MustCompile(`^people\.avatar$`), Out: "erase"},
+		},
+	}
+	output := scrubPolicy(mysql.NewContext(), input, policy)
+
+	row := `\((\d+),(-?\d+),(\d+\.\d+|\d+),([\d.e+]+),(NULL),(b'[01]{1,3}'),'(\d{4}-\d{2}-\d{2}(?: \d{2}:\d{2}:\d{2}\.\d+)?)',(TRUE|FALSE)\)`
+	m := regexp.MustCompile(`VALUES ` + row + `,` + row + `;`).FindStringSubmatch(output)
+	if m == nil {
+		t.Fatalf("INSERT statement not properly sanitized: %s", output)
+	}
+	if m[1] != "1" || m[9] != "2" {
+		t.Errorf("unscrubbed values were modified")
+	}
+	if m[2] == "1985" || len(m[2]) != 4 || m[10] == "-1972" || len(m[10]) != 5 {
+		t.Errorf("integers not properly masked: %s, %s", m[2], m[10])
+	}
+	if m[3] == "52000.50" || len(m[3]) != len("52000.50") {
+		t.Errorf("decimal not properly masked: %s", m[3])
+	}
+	if m[7] == "1985-04-12" || m[15] == "2000-02-29 23:59:59.250" || len(m[15]) != len("2000-02-29 23:59:59.250") {
+		t.Errorf("dates not properly shifted: %s, %s", m[7], m[15])
+	}
+	if _, err := time.Parse("2006-01-02", m[7]); err != nil {
+		t.Errorf("invalid shifted date %q: %s", m[7], err)
+	}
+	if m[8] != "TRUE" || m[16] != "FALSE" {
+		t.Errorf("booleans were modified")
+	}
+}
+
+func TestInsertConstrained(t *testing.T) {
+	input := read(t, "insert-constrained.sql")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^users\.email$`), Out: "replace(someone@example.com)"},
+			{In: regexp.MustCompile(`^users\.nickname$`), Out: "replace(Anonymous)"},
+			{In: regexp.MustCompile(`^users\.age$`), Out: "erase"},
+			{In: regexp.MustCompile(`^users\.score$`), Out: "mask"},
+		},
+	}
+	ctx := mysql.NewContext()
+	if err := ctx.Scan(input); err != nil {
+		t.Fatalf("Scan failed: %s", err)
+	}
+	output := scrubPolicy(ctx, input, policy)
+
+	m := regexp.MustCompile(`VALUES \(1,'(.*?)','(.*?)',(\w+),(-?\d+)\),\(2,'(.*?)','(.*?)',(\w+),(-?\d+)\),\(3,'(.*?)','(.*?)',(\w+),NULL\);`).FindStringSubmatch(output)
+	if m == nil {
+		t.Fatalf("INSERT statement not properly sanitized: %s", output)
+	}
+	emails := []string{m[1], m[5], m[9]}
+	for _, email := range emails {
+		if len(email) > 16 || !strings.HasSuffix(email, "@example.com") {
+			t.Errorf("unique value was not disambiguated within length: %q", email)
+		}
+	}
+	if emails[0] == emails[1] || emails[1] == emails[2] || emails[0] == emails[2] {
+		t.Errorf("unique values are not distinct: %v", emails)
+	}
+	// only values that collide are disambiguated (but all are truncated)
+	if emails[0] != "some@example.com" {
+		t.Errorf("value without a collision was modified: %q", emails[0])
+	}
+	if m[2] != "Anony" || m[6] != "Anony" || m[10] != "Anony" {
+		t.Errorf("replaced values were not truncated: %q, %q, %q", m[2], m[6], m[10])
+	}
+	if m[3] != "0" || m[7] != "0" || m[11] != "0" {
+		t.Errorf("NOT NULL column was erased to %q, %q, %q", m[3], m[7], m[11])
+	}
+	for _, s := range []string{m[4], m[8]} {
+		if n, err := strconv.Atoi(s); err != nil || n < -128 || n > 127 {
+			t.Errorf("masked value %s is out of range", s)
+		}
+	}
+}
+
+func TestInsertShift(t *testing.T) {
+	input := read(t, "insert-events.sql")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^events\.(happened_at|signed_up_on)$`), Out: "shift(30, user_id)"},
+		},
+	}
+	output := scrubPolicy(mysql.NewContext(), input, policy)
+
+	m := regexp.MustCompile(`VALUES \(1,7,'(.*?)','(.*?)'\),\(2,7,'(.*?)','(.*?)'\),\(3,8,'(.*?)','(.*?)'\);`).FindStringSubmatch(output)
+	if m == nil {
+		t.Fatalf("INSERT statement not properly sanitized: %s", output)
+	}
+	parse := func(layout, s string) time.Time {
+		v, err := time.Parse(layout, s)
+		if err != nil {
+			t.Fatalf("invalid shifted date %q: %s", s, err)
+		}
+		return v
+	}
+	const datetime = "2006-01-02 15:04:05"
+	happened1, happened2 := parse(datetime, m[1]), parse(datetime, m[3])
+	signedUp1, signedUp2 := parse("2006-01-02", m[2]), parse("2006-01-02", m[4])
+	happened3 := parse(time.RFC3339, m[5])
+	parse("2006-01-02", m[6])
+
+	original := parse(datetime, "2021-03-04 10:00:00")
+	if offset := happened1.Sub(original); offset == 0 || offset > 30*24*time.Hour || offset < -30*24*time.Hour {
+		t.Errorf("date shifted by %s, want up to 30 days", offset)
+	}
+	if interval := happened2.Sub(happened1); interval != 25*time.Hour+30*time.Minute {
+		t.Errorf("interval between events of one entity changed to %s", interval)
+	}
+	if !signedUp1.Equal(signedUp2) || signedUp1.Sub(parse("2006-01-02", "2021-03-01")) != happened1.Sub(original) {
+		t.Errorf("dates of one entity were shifted by different offsets")
+	}
+	if happened3.Equal(happened1) {
+		t.Errorf("dates of different entities were shifted by the same offset")
+	}
+}
+
+func TestInsertConditional(t *testing.T) {
+	input := read(t, "insert-contacts.sql")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^contacts\.name$`), When: filtering.MustParseCondition("kind = 'company'"), Out: "replace(ACME)"},
+			{In: regexp.MustCompile(`^contacts\.name$`), Out: "replace(Jane)"},
+		},
+	}
+	output := scrubPolicy(mysql.NewContext(), input, policy)
+
+	if !strings.Contains(output, "VALUES (1,'person','Jane'),(2,'company','ACME'),(3,NULL,'Jane');") {
+		t.Errorf("INSERT statement not properly sanitized: %s", output)
+	}
+
+	// a typo in a condition stops scrubbing
+	policy.FieldName[0].When = filtering.MustParseCondition("knid = 'company'")
+	ctx := mysql.NewContext()
+	scrubPolicy(ctx, input, policy)
+	var ce *scrubbing.ConditionError
+	if err := context.Cause(ctx); !errors.As(err, &ce) {
+		t.Errorf("condition with unknown field did not cancel context: %v", err)
+	}
+}
+
+func TestInsertDerive(t *testing.T) {
+	input := read(t, "insert-derive.sql")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^users\.(first|last)_name$`), Out: "mask"},
+			{In: regexp.MustCompile(`^users\.email$`), Out: "derive({first_name}.{last_name}@example.com)"},
+		},
+	}
+	output := scrubPolicy(mysql.NewContext(), input, policy)
+
+	m := regexp.MustCompile(`VALUES \(1,'(\w+)','(\w+)','(.*?)'\),\(2,'(\w+)',NULL,'(.*?)'\);`).FindStringSubmatch(output)
+	if m == nil {
+		t.Fatalf("INSERT statement not properly sanitized: %s", output)
+	}
+	if m[1] == "Joe" || m[3] != m[1]+"."+m[2]+"@example.com" {
+		t.Errorf("email %q not derived from scrubbed names %q and %q", m[3], m[1], m[2])
+	}
+	if m[5] != m[4]+".@example.com" {
+		t.Errorf("email %q not derived from scrubbed name %q and NULL", m[5], m[4])
+	}
+}
+
+func TestInsertDomain(t *testing.T) {
+	input := read(t, "insert-domain.sql")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^users\.email$`), Out: "domain(email)"},
+			{In: regexp.MustCompile(`^invoices\.billing_email$`), Out: "domain(email)"},
+			{In: regexp.MustCompile(`^audit_log\.actor$`), Out: "domain(email)"},
+		},
+		Domains: map[string]scrubbing.Disposition{
+			"email": "redact('^[^@]+')",
+		},
+	}
+	output := scrubPolicy(mysql.NewContext(), input, policy)
+
+	users := regexp.MustCompile(`VALUES \(1,'(.*?)'\),\(2,'(.*?)'\);`).FindStringSubmatch(output)
+	invoices := regexp.MustCompile(`VALUES \(10,'(.*?)'\),\(11,'(.*?)'\);`).FindStringSubmatch(output)
+	audit := regexp.MustCompile(`VALUES \(100,'(.*?)'\);`).FindStringSubmatch(output)
+	if users == nil || invoices == nil || audit == nil {
+		t.Fatalf("INSERT statements not properly sanitized: %s", output)
+	}
+	joe, ann := users[1], users[2]
+	if joe == "joe@foo.com" || !strings.HasSuffix(joe, "@foo.com") || !strings.HasSuffix(ann, "@bar.org") {
+		t.Errorf("users not properly sanitized: %q, %q", joe, ann)
+	}
+	if invoices[1] != ann || invoices[2] != joe || audit[1] != joe {
+		t.Errorf("fields of one domain were scrubbed differently: %s", output)
+	}
+}
+
+func TestInsertFiltered(t *testing.T) {
+	input := read(t, "insert-filtered.sql")
+	ctx := mysql.NewContext()
+	if err := ctx.Scan(input); err != nil {
+		t.Fatalf("Scan failed: %s", err)
+	}
+	if pk := ctx.PrimaryKeys["accounts"]; !reflect.DeepEqual(pk, []string{"tenant", "id"}) {
+		t.Errorf("primary key of accounts = %v", pk)
+	}
+	ctx.Filter = &filtering.Policy{Tables: map[string]*filtering.Table{
+		"accounts": {Where: "deleted_at IS NULL OR deleted_at < '2021-01-01'"},
+	}}
+	if errs := ctx.Filter.Validate(); errs != nil {
+		t.Fatalf("Validate: %v", errs)
+	}
+	output := scrubPolicy(ctx, input, nullPolicy)
+
+	if !strings.Contains(output, "INSERT INTO `accounts` VALUES (1,1,'kept','2020-01-01 00:00:00'),(1,2,'kept',NULL),(1,3,'kept',NULL);") {
+		t.Errorf("rows that satisfy the filter were not kept: %s", output)
+	}
+	if !strings.Contains(output, "INSERT INTO `accounts` VALUES (2,1,'gone','2020-01-01 00:00:00');") {
+		t.Errorf("rows that fail the filter were not removed: %s", output)
+	}
+	if !strings.Contains(output, "INSERT INTO `logs`") {
+		t.Errorf("rows of unfiltered tables were not kept: %s", output)
+	}
+
+	// sampling 0.001% of rows will drop entire statements
+	ctx.Filter.Tables["accounts"] = &filtering.Table{Sample: 0.001}
+	output = scrubPolicy(ctx, input, nullPolicy)
+	if strings.Contains(output, "INSERT INTO `accounts`") || !strings.Contains(output, "INSERT INTO `logs`") {
+		t.Errorf("empty statements were not omitted: %s", output)
+	}
+
+	ctx.Filter.Tables["logs"] = &filtering.Table{Where: "level = 'error'"}
+	if errs := ctx.Filter.Validate(); errs != nil {
+		t.Fatalf("Validate: %v", errs)
+	}
+	scrubPolicy(ctx, input, nullPolicy)
+	var unknown *filtering.UnknownColumnError
+	if err := context.Cause(ctx); !errors.As(err, &unknown) {
+		t.Errorf("filter with unknown column did not cancel context: %v", err)
+	}
+}
+
+func TestInsertSampled(t *testing.T) {
+	sample := func(input string) (string, int) {
+		ctx := mysql.NewContext()
+		ctx.Filter = &filtering.Policy{Tables: map[string]*filtering.Table{
+			"t": {Sample: 50},
+		}}
+		if errs := ctx.Filter.Validate(); errs != nil {
+			t.Fatalf("Validate: %v", errs)
+		}
+		output := scrubPolicy(ctx, input, nullPolicy)
+		if err := context.Cause(ctx); err != nil {
+			t.Fatalf("sampling failed: %s", err)
+		}
+		return output, strings.Count(output, "INSERT INTO")
+	}
+
+	// without schema or column names, rows are identified by their first value
+	var sb strings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&sb, "INSERT INTO `t` VALUES (%d,'same');\n", i)
+	}
+	if _, kept := sample(sb.String()); kept == 0 || kept == 100 {
+		t.Errorf("sampling kept %d of 100 rows", kept)
+	}
+
+	// the primary key of a CREATE TABLE in the input identifies rows
+	sb.Reset()
+	sb.WriteString("CREATE TABLE `t` (`name` varchar(8), `id` int NOT NULL, PRIMARY KEY (`id`));\n")
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&sb, "INSERT INTO `t` VALUES ('a',%d),('b',%d);\n", i, i)
+	}
+	output, kept := sample(sb.String())
+	if kept == 0 || kept == 100 {
+		t.Errorf("sampling kept %d of 100 statements", kept)
+	}
+	if strings.Count(output, "('a',") != kept || strings.Count(output, "('b',") != kept {
+		t.Errorf("rows were not sampled by primary key: %s", output)
+	}
+}
+
+func TestInsertSubset(t *testing.T) {
+	input := read(t, "insert-subset.sql")
+	ctx := mysql.NewContext()
+	ctx.Filter = &filtering.Policy{
+		Tables:  map[string]*filtering.Table{"users": {Where: "id <> 3"}},
+		Cascade: true,
+	}
+	if errs := ctx.Filter.Validate(); errs != nil {
+		t.Fatalf("Validate: %v", errs)
+	}
+	if err := ctx.Subset(strings.NewReader(input)); err != nil {
+		t.Fatalf("Subset: %s", err)
+	}
+	fks := ctx.ForeignKeys["orders"]
+	if len(fks) != 1 || !reflect.DeepEqual(fks[0], mysql.ForeignKey{Columns: []string{"user_id"}, Table: "users", RefColumns: []string{"id"}}) {
+		t.Errorf("foreign keys of orders = %v", fks)
+	}
+	output := scrubPolicy(ctx, input, nullPolicy)
+
+	// user 2 was referred by user 3, so is omitted along with user 3; then
+	// their orders and order items are omitted, too
+	for _, exp := range []string{
+		"INSERT INTO `order_items` VALUES (1,10,'apple'),(4,NULL,'loose');",
+		"INSERT INTO `orders` VALUES (10,1);",
+		"INSERT INTO `users` VALUES (1,'joe@example.com',NULL);",
+	} {
+		if !strings.Contains(output, exp) {
+			t.Errorf("output lacks %s\n%s", exp, output)
+		}
+	}
+}
+
+func TestInsertTables(t *testing.T) {
+	input := read(t, "insert-tables.sql")
+	policy := &scrubbing.Policy{
+		Tables: []scrubbing.TableRule{
+			{Table: "sessions", Out: "drop"},
+			{Table: "Settings", Out: "replace(testdata/fixtures-settings.sql)"},
+			{Table: "tokens", Out: "replace(testdata/fixtures-tokens.sql)"},
+		},
+	}
+	if errs := policy.Validate(nil); errs != nil {
+		t.Fatalf("Validate: %v", errs)
+	}
+	ctx := mysql.NewContext()
+	output := scrubPolicy(ctx, input, policy)
+	if err := context.Cause(ctx); err != nil {
+		t.Fatalf("context was unexpectedly canceled: %v", err)
+	}
+
+	for _, secret := range []string{"secret-", "hunter2", "sk_live", "whsec"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("output contains %s\n%s", secret, output)
+		}
+	}
+	for _, exp := range []string{
+		"CREATE TABLE `sessions`",
+		"LOCK TABLES `sessions` WRITE;\nUNLOCK TABLES;",
+		"PRIMARY KEY (`name`));\nINSERT INTO `settings` VALUES ('smtp_password','changeme'),('api_key','test');\nLOCK TABLES `settings` WRITE;\nUNLOCK TABLES;",
+		"INSERT INTO `users` VALUES (1,'joe@example.com');",
+		"PRIMARY KEY (`id`));\nINSERT INTO `tokens` VALUES (1,'fixture');\nLOCK TABLES `tokens` WRITE;\nUNLOCK TABLES;",
+	} {
+		if !strings.Contains(output, exp) {
+			t.Errorf("output lacks %s\n%s", exp, output)
+		}
+	}
+	if n := strings.Count(output, "changeme"); n != 1 {
+		t.Errorf("fixtures were output %d times\n%s", n, output)
+	}
+
+	// without CREATE TABLE (mysqldump --no-create-info), fixtures follow LOCK TABLES
+	input = regexp.MustCompile("(?m)^CREATE TABLE .*\n").ReplaceAllString(input, "")
+	output = scrubPolicy(mysql.NewContext(), input, policy)
+	for _, exp := range []string{
+		"LOCK TABLES `settings` WRITE;\nINSERT INTO `settings` VALUES ('smtp_password','changeme'),('api_key','test');\nUNLOCK TABLES;",
+		"LOCK TABLES `tokens` WRITE;\nINSERT INTO `tokens` VALUES (1,'fixture');\nUNLOCK TABLES;",
+	} {
+		if !strings.Contains(output, exp) {
+			t.Errorf("output lacks %s\n%s", exp, output)
+		}
+	}
+
+	policy.Tables[1].Out = "replace(testdata/missing.sql)"
+	if errs := policy.Validate(nil); len(errs) != 1 {
+		t.Errorf("missing fixtures: got %v", errs)
+	}
+}
+
+func TestInsertBucket(t *testing.T) {
+	input := read(t, "insert-typed.sql")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^people\.salary$`), Out: "bucket(10000)"},
+			{In: regexp.MustCompile(`^people\.birth_year$`), Out: "bucket(5)"},
+		},
+	}
+	output := scrubPolicy(mysql.NewContext(), input, policy)
+
+	if strings.Index(output, "VALUES (1,1985,50000.00,") < 0 {
+		t.Errorf("INSERT statement not properly bucketed: %s", output)
+	}
+}
+
+func TestInsertEncrypt(t *testing.T) {
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^accounts\.balance$`), Out: "encrypt(support)"},
+		},
+	}
+	policy.SetKey("support", key)
+
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO `accounts` (`id`, `balance`) VALUES ")
+	for i := 100; i < 1000; i++ {
+		if i > 100 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, "(%d,%d)", i, i)
+	}
+	sb.WriteString(";\n")
+	output := scrubPolicy(mysql.NewContext(), sb.String(), policy)
+
+	text, _ := fpe.NewText(key)
+	rows := regexp.MustCompile(`\((\d+),(-?\d+)\)`).FindAllStringSubmatch(output, -1)
+	if len(rows) != 900 {
+		t.Fatalf("INSERT statement not properly sanitized: %s", output)
+	}
+	for _, row := range rows {
+		if pt := text.DecryptNumber(row[2]); pt != row[1] {
+			t.Errorf("balance %s decrypted to %s, want %s", row[2], pt, row[1])
+		}
+	}
+}
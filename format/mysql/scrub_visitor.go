@@ -1,7 +1,11 @@
 package mysql
 
 import (
+	"strconv"
+
 	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/parser/opcode"
 	"github.com/pingcap/tidb/parser/test_driver"
 	"github.com/xeger/pipeclean/scrubbing"
 )
@@ -10,6 +14,9 @@ type scrubVisitor struct {
 	ctx      *Context
 	scrubber *scrubbing.Scrubber
 	insert   *insertState
+	// Scrubber bound to the current row, if the policy uses rows.
+	row      *scrubbing.Scrubber
+	usesRows bool
 }
 
 // ScrubStatement sensitive data from an SQL AST.
@@ -19,6 +26,9 @@ func (v *scrubVisitor) ScrubStatement(stmt ast.StmtNode) (ast.StmtNode, bool) {
 	switch typed := stmt.(type) {
 	case *ast.InsertStmt:
 		if doInserts {
+			if !v.ctx.filter(typed) {
+				return nil, true
+			}
 			v.insert = newInsertState(typed)
 			stmt.Accept(v)
 			v.insert = nil
@@ -51,23 +61,125 @@ func (v *scrubVisitor) Enter(in ast.Node) (ast.Node, bool) {
 			defer func() {
 				v.insert.Advance()
 			}()
-			switch typed.Kind() {
-			case test_driver.KindString:
-				datum := test_driver.Datum{}
-				s := typed.Datum.GetString()
-				names := v.insert.Names()
-				if v.scrubber.EraseString(s, names) {
-					datum.SetNull()
-				} else {
-					datum.SetString(v.scrubber.ScrubString(s, names))
+			names := v.insert.Names()
+			if v.insert.valueIndex < v.insert.rowLength && len(names) > 0 {
+				v.scrubber.Classifier.Check(classifiedName(names), names)
+			}
+			sc := v.scrubber
+			if v.usesRows {
+				if row := v.insert.Row(); row != nil {
+					v.row = v.scrubber.WithRow(row)
 				}
-				return &test_driver.ValueExpr{Datum: datum}, true
+				sc = v.row
+			}
+			datum, ok := scrubDatum(sc, typed, names)
+			if !ok {
+				break
 			}
+			if col := v.insert.Column(v.ctx); col != nil {
+				disposition, _ := sc.MatchFieldName(names)
+				orig, _ := exprString(typed)
+				datum = v.ctx.constrain(names[1], col, orig, datum, disposition != "" && disposition.Action() != "pass")
+			}
+			if datum.Kind() == test_driver.KindBinaryLiteral {
+				// retain type information so hex literals remain hex literals
+				return &test_driver.ValueExpr{TexprNode: typed.TexprNode, Datum: datum}, true
+			}
+			return &test_driver.ValueExpr{Datum: datum}, true
 		}
 	}
 	return in, false
 }
 
+// Scrubs a single value, preserving its type. Returns false if the value is
+// of a kind that is never scrubbed (NULL, booleans, etc).
+func scrubDatum(sc *scrubbing.Scrubber, typed *test_driver.ValueExpr, names []string) (test_driver.Datum, bool) {
+	datum := test_driver.Datum{}
+	switch typed.Kind() {
+	case test_driver.KindString:
+		s := typed.Datum.GetString()
+		if sc.EraseString(s, names) {
+			datum.SetNull()
+		} else {
+			datum.SetString(sc.ScrubString(s, names))
+		}
+	case test_driver.KindInt64:
+		if typed.Type.GetFlag()&mysql.IsBooleanFlag != 0 {
+			return datum, false
+		}
+		s := strconv.FormatInt(typed.GetInt64(), 10)
+		datum = numberDatum(sc.ScrubNumber(s, names))
+	case test_driver.KindUint64:
+		s := strconv.FormatUint(typed.GetUint64(), 10)
+		datum = numberDatum(sc.ScrubNumber(s, names))
+	case test_driver.KindMysqlDecimal:
+		s := typed.GetMysqlDecimal().String()
+		datum = numberDatum(sc.ScrubNumber(s, names))
+	case test_driver.KindFloat32, test_driver.KindFloat64:
+		// format without an exponent so masking cannot change the magnitude
+		s := strconv.FormatFloat(typed.GetFloat64(), 'f', -1, 64)
+		scrubbed := sc.ScrubNumber(s, names)
+		if f, err := strconv.ParseFloat(scrubbed, 64); err == nil {
+			datum.SetFloat64(f)
+		} else {
+			datum = numberDatum(scrubbed)
+		}
+	case test_driver.KindBinaryLiteral:
+		if b := sc.ScrubBytes(typed.GetBinaryLiteral(), names); b == nil {
+			datum.SetNull()
+		} else {
+			datum.SetBinaryLiteral(b)
+		}
+	default:
+		return datum, false
+	}
+	return datum, true
+}
+
+// Returns the string form of a literal value (e.g. for use in a Row), or
+// false if the expression is not a literal or is NULL.
+func exprString(expr ast.ExprNode) (string, bool) {
+	switch typed := expr.(type) {
+	case *test_driver.ValueExpr:
+		switch typed.Kind() {
+		case test_driver.KindString:
+			return typed.GetString(), true
+		case test_driver.KindInt64:
+			return strconv.FormatInt(typed.GetInt64(), 10), true
+		case test_driver.KindUint64:
+			return strconv.FormatUint(typed.GetUint64(), 10), true
+		case test_driver.KindMysqlDecimal:
+			return typed.GetMysqlDecimal().String(), true
+		case test_driver.KindFloat32, test_driver.KindFloat64:
+			return strconv.FormatFloat(typed.GetFloat64(), 'f', -1, 64), true
+		}
+	case *ast.UnaryOperationExpr:
+		if s, ok := exprString(typed.V); ok && typed.Op == opcode.Minus {
+			return "-" + s, true
+		}
+	}
+	return "", false
+}
+
+// numberDatum converts the decimal representation of a scrubbed number
+// into the narrowest datum that can hold it. Empty strings become NULL, and
+// anything that isn't a number (e.g. a replacement literal) becomes a string.
+func numberDatum(s string) test_driver.Datum {
+	datum := test_driver.Datum{}
+	if s == "" {
+		datum.SetNull()
+	} else if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		datum.SetInt64(i)
+	} else if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		datum.SetUint64(u)
+	} else if d := new(test_driver.MyDecimal); d.FromString([]byte(s)) == nil {
+		datum.SetMysqlDecimal(d)
+	} else {
+		datum.SetString(s)
+	}
+	return datum
+}
+
 func (v *scrubVisitor) Leave(in ast.Node) (ast.Node, bool) {
 	return in, true
 }
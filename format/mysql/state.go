@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/pingcap/tidb/parser/ast"
+	"github.com/xeger/pipeclean/scrubbing"
 )
 
 type insertState struct {
@@ -15,6 +16,8 @@ type insertState struct {
 	rowLength int
 	// Number of ValueExpr seen so far across all rows of current statement.
 	valueIndex int
+	// Value tuples of the current statement.
+	lists [][]ast.ExprNode
 }
 
 func newInsertState(stmt *ast.InsertStmt) *insertState {
@@ -27,7 +30,7 @@ func newInsertState(stmt *ast.InsertStmt) *insertState {
 			panic(fmt.Sprintf("inconsistent INSERT row lengths: %d prior vs %d next", rowLength, len(list)))
 		}
 	}
-	return &insertState{rowLength: rowLength}
+	return &insertState{rowLength: rowLength, lists: stmt.Lists}
 }
 
 // Advance increments the column-value index so that Names() remains accurate.
@@ -43,6 +46,10 @@ func (is *insertState) Names() []string {
 	if is.rowLength > 0 {
 		colIdx = colIdx % is.rowLength
 	}
+	return is.namesAt(colIdx)
+}
+
+func (is *insertState) namesAt(colIdx int) []string {
 	names := make([]string, 0, 3)
 	if len(is.tableName) > 0 {
 		if len(is.columnNames) > 0 {
@@ -56,9 +63,39 @@ func (is *insertState) Names() []string {
 	return names
 }
 
+// Row returns the values of the row that contains the next ValueExpr, if
+// the next ValueExpr is the first of its row; otherwise it returns nil.
+func (is *insertState) Row() scrubbing.Row {
+	if is.rowLength == 0 || is.valueIndex%is.rowLength != 0 {
+		return nil
+	}
+	row := make(scrubbing.Row, 3*is.rowLength)
+	for colIdx, expr := range is.lists[is.valueIndex/is.rowLength] {
+		if s, ok := exprString(expr); ok {
+			row.Set(is.namesAt(colIdx), s)
+		} else {
+			row.SetNull(is.namesAt(colIdx))
+		}
+	}
+	return row
+}
+
+// Column returns the definition of the column to which the next ValueExpr
+// will apply, or nil if it is unknown.
+func (is *insertState) Column(ctx *Context) *Column {
+	if len(is.columnNames) == 0 {
+		return nil
+	}
+	colIdx := is.valueIndex
+	if is.rowLength > 0 {
+		colIdx = colIdx % is.rowLength
+	}
+	return ctx.column(is.tableName, is.columnNames[colIdx])
+}
+
 // If column names were omitted from the SQL INSERT statement, infer them from the previously-scanned table schema.
 func (is *insertState) ObserveContext(ctx *Context) {
 	if is.valueIndex == 0 && len(is.columnNames) == 0 {
-		is.columnNames = ctx.TableColumns[is.tableName]
+		is.columnNames = ctx.columns(is.tableName)
 	}
 }
@@ -0,0 +1,105 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Strictness determines how scrubbing handles input that cannot be parsed.
+type Strictness int
+
+const (
+	// Lenient emits unparseable input unchanged, assuming that it is a comment.
+	Lenient Strictness = iota
+	// Redact emits comments unchanged, but replaces any other unparseable
+	// input with RedactedMarker.
+	Redact
+	// Abort emits comments unchanged, but cancels the Context with an
+	// *UnparseableError upon encountering any other unparseable input.
+	Abort
+)
+
+// RedactedMarker replaces unparseable input in Redact mode.
+const RedactedMarker = "-- pipeclean: removed unparseable input"
+
+// Longest snippet of input to include in an UnparseableError.
+const maxSnippetLength = 64
+
+// UnparseableError describes input that could not be parsed and that does
+// not appear to be a comment.
+type UnparseableError struct {
+	// Line is the (1-based) number of the offending input line.
+	Line int
+	// Snippet is a prefix of the offending input line.
+	Snippet string
+}
+
+func (e *UnparseableError) Error() string {
+	return fmt.Sprintf("unparseable input at line %d: %q", e.Line, e.Snippet)
+}
+
+// Finds the first line of text that contains something other than comments,
+// whitespace or a DELIMITER command. Returns the 0-based index of the line,
+// or -1 if there is no such line. Executable comments (/*!...*/) and
+// optimizer hints are statements that MySQL runs, so they are not comments.
+func findData(text string) (int, string) {
+	blockComment := false
+	for idx, line := range strings.Split(text, "\n") {
+		if !blockComment && reDelimiter.MatchString(line) {
+			continue
+		}
+	scan:
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case blockComment:
+				if c == '*' && i+1 < len(line) && line[i+1] == '/' {
+					blockComment = false
+					i++
+				}
+			case c == '/' && strings.HasPrefix(line[i:], "/*!"), c == '/' && strings.HasPrefix(line[i:], "/*+"):
+				return idx, line
+			case c == '/' && i+1 < len(line) && line[i+1] == '*':
+				blockComment = true
+				i++
+			case c == '#' || strings.HasPrefix(line[i:], "--"):
+				break scan
+			case c == ';' || c == ' ' || c == '\t' || c == '\r':
+			default:
+				return idx, line
+			}
+		}
+	}
+	return -1, ""
+}
+
+// Decides what to emit in lieu of a statement that could not be parsed.
+//
+// Unterminated statements may have swallowed any amount of data that
+// follows them, so they are never emitted, even by Lenient.
+func (ctx *Context) reject(stmt Statement) string {
+	if ctx.Strict == Lenient && !stmt.Unterminated {
+		return stmt.Text
+	}
+
+	idx, line := findData(stmt.Text)
+	if idx < 0 {
+		if !stmt.Unterminated {
+			return stmt.Text
+		}
+		// e.g. a comment that is never closed
+		idx, line = 0, strings.SplitN(stmt.Text, "\n", 2)[0]
+	}
+
+	switch ctx.Strict {
+	case Abort:
+		snippet := strings.TrimSpace(line)
+		if len(snippet) > maxSnippetLength {
+			snippet = snippet[:maxSnippetLength] + "..."
+		}
+		ctx.cancel(&UnparseableError{Line: stmt.Line + idx, Snippet: snippet})
+		return ""
+	default:
+		return RedactedMarker + "\n"
+	}
+}
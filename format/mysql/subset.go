@@ -0,0 +1,180 @@
+package mysql
+
+import (
+	"io"
+	"strings"
+
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+)
+
+// ForeignKey describes a FOREIGN KEY ... REFERENCES constraint.
+type ForeignKey struct {
+	// Columns of the referencing table.
+	Columns []string
+	// Table is the name of the referenced table.
+	Table string
+	// RefColumns are the columns of the referenced table, in the same order.
+	RefColumns []string
+}
+
+// Identifies the referenced columns of a foreign key.
+func (fk ForeignKey) refKey() string {
+	return strings.Join(fk.RefColumns, ",")
+}
+
+// A row of a referenced table, as recorded by Subset.
+type subsetRow struct {
+	// Values of each set of referenced columns (by refKey).
+	keys map[string]string
+	// Values of each foreign key of the row (by index in ctx.ForeignKeys);
+	// NULL keys are omitted.
+	refs map[int]string
+	kept bool
+}
+
+// Subset reads a dump in order to decide which rows to keep when filtering
+// cascades along foreign keys (see filtering.Policy.Cascade). Then, while
+// scrubbing the same dump, rows that refer to omitted rows are omitted as
+// well, so that the output is referentially intact.
+//
+// Because a dump may insert rows into a table before the rows that they
+// refer to (or before the table that refers to them is even created), Subset
+// reads the dump twice: first to find foreign keys in CREATE TABLE
+// statements (which are recorded in ctx as if scanned), and then to record
+// the keys of every row of each referenced table. Only keys are kept in
+// memory, not entire rows.
+func (ctx *Context) Subset(r io.ReadSeeker) error {
+	p := parser.New()
+	siv := &schemaInfoVisitor{info: ctx}
+	err := ctx.eachStatement(r, p, "CREATE", func(stmt ast.StmtNode) error {
+		// tables provided as context are already known
+		ctx.learnStatement(siv, stmt)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// Tables that are referenced, and the column sets by which they are.
+	referenced := make(map[string]map[string][]string)
+	for _, fks := range ctx.ForeignKeys {
+		for _, fk := range fks {
+			if referenced[fk.Table] == nil {
+				referenced[fk.Table] = make(map[string][]string)
+			}
+			referenced[fk.Table][fk.refKey()] = fk.RefColumns
+		}
+	}
+
+	rowsOf := make(map[string][]*subsetRow)
+	err = ctx.eachStatement(r, p, "INSERT", func(stmt ast.StmtNode) error {
+		insert, ok := stmt.(*ast.InsertStmt)
+		if !ok {
+			return nil
+		}
+		rows := ctx.insertRows(insert)
+		refs := referenced[rows.tableName]
+		if refs == nil {
+			return nil
+		}
+		if rowsOf[rows.tableName] == nil {
+			// the table has rows, even if none pass its filter
+			rowsOf[rows.tableName] = []*subsetRow{}
+		}
+		for i := range rows.lists {
+			keep, err := ctx.keepOwn(rows, i)
+			if err != nil {
+				return err
+			}
+			if !keep {
+				continue
+			}
+			row := &subsetRow{keys: make(map[string]string, len(refs)), refs: make(map[int]string), kept: true}
+			for refKey, columns := range refs {
+				if key, ok := rows.key(i, columns); ok {
+					row.keys[refKey] = key
+				}
+			}
+			for j, fk := range ctx.ForeignKeys[rows.tableName] {
+				if key, ok := rows.key(i, fk.Columns); ok {
+					row.refs[j] = key
+				}
+			}
+			rowsOf[rows.tableName] = append(rowsOf[rows.tableName], row)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Omit rows that refer to omitted rows until nothing changes, so that
+	// chains (and cycles) of foreign keys are followed to the end.
+	retained := make(map[string]map[string]map[string]bool, len(rowsOf))
+	for changed := true; changed; {
+		for table, rows := range rowsOf {
+			sets := make(map[string]map[string]bool, len(referenced[table]))
+			for refKey := range referenced[table] {
+				sets[refKey] = make(map[string]bool)
+			}
+			for _, row := range rows {
+				if row.kept {
+					for refKey, key := range row.keys {
+						sets[refKey][key] = true
+					}
+				}
+			}
+			retained[table] = sets
+		}
+
+		changed = false
+		for table, rows := range rowsOf {
+			for _, row := range rows {
+				if !row.kept {
+					continue
+				}
+				for j, key := range row.refs {
+					fk := ctx.ForeignKeys[table][j]
+					if sets := retained[fk.Table]; sets != nil && !sets[fk.refKey()][key] {
+						row.kept = false
+						changed = true
+						break
+					}
+				}
+			}
+		}
+	}
+	ctx.retained = retained
+	return nil
+}
+
+// Parses each statement of a dump that begins with the given keyword, and
+// calls fn with each of the resulting ASTs.
+func (ctx *Context) eachStatement(r io.Reader, p *parser.Parser, keyword string, fn func(ast.StmtNode) error) error {
+	reader := NewReader(nil, r)
+	for {
+		stmt, err := reader.ReadStatement()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if _, line := findData(stmt.Text); !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(line)), keyword) {
+			continue
+		}
+		stmts, _, err := p.Parse(stmt.Text, "", "")
+		if err != nil {
+			// scrubbing will deal with unparseable input
+			continue
+		}
+		for _, in := range stmts {
+			if err := fn(in); err != nil {
+				return err
+			}
+		}
+	}
+}
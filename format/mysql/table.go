@@ -0,0 +1,44 @@
+package mysql
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+// Applies the policy's table rules to a statement. Returns true if the
+// statement is an INSERT into a table whose data is dropped or replaced,
+// i.e. the statement should be omitted from output.
+func tableData(policy *scrubbing.Policy, stmt ast.StmtNode) bool {
+	insert, ok := stmt.(*ast.InsertStmt)
+	if !ok || len(policy.Tables) == 0 {
+		return false
+	}
+	return policy.MatchTable(insertTableName(insert)) != ""
+}
+
+// Returns the fixtures that replace the data of the named table, if any.
+// Fixtures are output after the first statement that refers to the table
+// (see Statement.Table), so that they appear exactly once and in the same
+// place on every run: after the table's CREATE TABLE or LOCK TABLES, or in
+// place of its first INSERT if neither precedes it.
+//
+// If the fixtures cannot be read, ctx is canceled.
+func (ctx *Context) fixtures(policy *scrubbing.Policy, tableName string) string {
+	disposition := policy.MatchTable(tableName)
+	if disposition.Action() != "replace" {
+		return ""
+	}
+	fixtures, err := os.ReadFile(disposition.Parameters()[0])
+	if err != nil {
+		ctx.cancel(err)
+		return ""
+	}
+	text := string(fixtures)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text
+}
@@ -0,0 +1 @@
+INSERT INTO `settings` VALUES ('smtp_password','changeme'),('api_key','test');
\ No newline at end of file
@@ -0,0 +1 @@
+INSERT INTO `tokens` VALUES (1,'fixture');
@@ -0,0 +1,11 @@
+CREATE TABLE `users` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `email` varchar(16) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `nickname` varchar(5) DEFAULT NULL,
+  `age` tinyint(3) unsigned NOT NULL,
+  `score` tinyint(4) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `index_users_on_email` (`email`)
+);
+
+INSERT INTO `users` VALUES (1,'joe@example.com','Joey',42,120),(2,'jane@example.com','Janie',37,-99),(3,'JOE@example.com','Joe',29,NULL);
@@ -0,0 +1 @@
+INSERT INTO `contacts` (`id`, `kind`, `name`) VALUES (1,'person','Joe Smith'),(2,'company','Initech'),(3,NULL,'Ann Lee');
@@ -0,0 +1 @@
+INSERT INTO `users` (`id`, `first_name`, `last_name`, `email`) VALUES (1,'Joe','Smith','jsmith@example.org'),(2,'Ann',NULL,'ann@example.org');
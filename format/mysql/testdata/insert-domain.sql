@@ -0,0 +1,3 @@
+INSERT INTO `users` (`id`, `email`) VALUES (1,'joe@foo.com'),(2,'ann@bar.org');
+INSERT INTO `invoices` (`id`, `billing_email`) VALUES (10,'ann@bar.org'),(11,'joe@foo.com');
+INSERT INTO `audit_log` (`id`, `actor`) VALUES (100,'joe@foo.com');
@@ -0,0 +1 @@
+INSERT INTO `events` (`id`, `user_id`, `happened_at`, `signed_up_on`) VALUES (1,7,'2021-03-04 10:00:00','2021-03-01'),(2,7,'2021-03-05 11:30:00','2021-03-01'),(3,8,'2021-03-04T10:00:00Z','2020-12-31');
@@ -0,0 +1,4 @@
+CREATE TABLE `accounts` (`tenant` int NOT NULL, `id` int NOT NULL, `name` varchar(32), `deleted_at` datetime, PRIMARY KEY (`tenant`, `id`));
+INSERT INTO `accounts` VALUES (1,1,'kept','2020-01-01 00:00:00'),(1,2,'kept',NULL),(1,3,'kept',NULL);
+INSERT INTO `accounts` VALUES (2,1,'gone','2020-01-01 00:00:00'),(2,2,'gone','2021-06-01 00:00:00');
+INSERT INTO `logs` (`id`, `message`) VALUES (1,'hello');
@@ -0,0 +1,15 @@
+-- Dumped with --skip-extended-insert, then hand-edited
+LOCK TABLES `users` WRITE;
+INSERT INTO `users` (`id`, `email`, `bio`)
+VALUES (1, 'joe@foo.com', 'line one;
+line two -- not a comment');
+/* a comment; that spans
+   several lines */
+INSERT INTO `users` (`id`, `email`, `bio`) VALUES (2, 'gophers@google.com', "it's \"quoted\"");
+UNLOCK TABLES;
+DELIMITER ;;
+CREATE PROCEDURE `p`()
+BEGIN
+  INSERT INTO `users` (`id`, `email`) VALUES (3, 'proc@foo.com');
+END ;;
+DELIMITER ;
@@ -0,0 +1,6 @@
+CREATE TABLE `order_items` (`id` int NOT NULL, `order_id` int, `sku` varchar(16), PRIMARY KEY (`id`), CONSTRAINT `fk_items_orders` FOREIGN KEY (`order_id`) REFERENCES `orders` (`id`));
+INSERT INTO `order_items` VALUES (1,10,'apple'),(2,11,'pear'),(3,12,'plum'),(4,NULL,'loose');
+CREATE TABLE `orders` (`id` int NOT NULL, `user_id` int NOT NULL, PRIMARY KEY (`id`), KEY `user_id` (`user_id`), CONSTRAINT `fk_orders_users` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`));
+INSERT INTO `orders` VALUES (10,1),(11,2),(12,3);
+CREATE TABLE `users` (`id` int NOT NULL, `email` varchar(64), `referrer_id` int, PRIMARY KEY (`id`), CONSTRAINT `fk_users_referrer` FOREIGN KEY (`referrer_id`) REFERENCES `users` (`id`));
+INSERT INTO `users` VALUES (1,'joe@example.com',NULL),(2,'ann@example.com',3),(3,'bob@example.com',NULL);
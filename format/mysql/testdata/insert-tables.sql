@@ -0,0 +1,17 @@
+CREATE TABLE `sessions` (`id` int NOT NULL, `token` varchar(64), PRIMARY KEY (`id`));
+LOCK TABLES `sessions` WRITE;
+INSERT INTO `sessions` VALUES (1,'secret-1'),(2,'secret-2');
+INSERT INTO `sessions` VALUES (3,'secret-3');
+UNLOCK TABLES;
+CREATE TABLE `settings` (`name` varchar(32) NOT NULL, `value` varchar(64), PRIMARY KEY (`name`));
+LOCK TABLES `settings` WRITE;
+INSERT INTO `settings` VALUES ('smtp_password','hunter2'),('api_key','sk_live_123');
+INSERT INTO `settings` VALUES ('webhook_secret','whsec_456');
+UNLOCK TABLES;
+CREATE TABLE `users` (`id` int NOT NULL, `email` varchar(64), PRIMARY KEY (`id`));
+LOCK TABLES `users` WRITE;
+INSERT INTO `users` VALUES (1,'joe@example.com');
+UNLOCK TABLES;
+CREATE TABLE `tokens` (`id` int NOT NULL, `token` varchar(64), PRIMARY KEY (`id`));
+LOCK TABLES `tokens` WRITE;
+UNLOCK TABLES;
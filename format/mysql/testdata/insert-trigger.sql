@@ -0,0 +1,6 @@
+/*!40101 SET NAMES utf8mb4 */;
+/*!50003 SET @saved_cs_client      = @@character_set_client */ ;
+DELIMITER ;;
+/*!50003 CREATE*/ /*!50017 DEFINER=`root`@`localhost`*/ /*!50003 TRIGGER `users_audit` AFTER INSERT ON `users` FOR EACH ROW INSERT INTO `audit` (`id`, `email`) VALUES (1, 'proc@foo.com') */;;
+DELIMITER ;
+/*!99999 FROBNICATE 'hidden@foo.com' */;
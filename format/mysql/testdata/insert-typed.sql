@@ -0,0 +1 @@
+INSERT INTO `people` (`id`, `birth_year`, `salary`, `height`, `avatar`, `flags`, `born_on`, `active`) VALUES (1,1985,52000.50,1.82,0x89504E47,b'101','1985-04-12',TRUE),(2,-1972,18446744073709551615,1.5e3,x'FFD8',b'1','2000-02-29 23:59:59.250',FALSE);
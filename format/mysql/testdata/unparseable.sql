@@ -0,0 +1,5 @@
+-- MySQL dump 10.13
+/*!40101 SET NAMES utf8mb4 */;
+# a shell-style comment
+INSERT INTO `users` (`id`, `email`) VALUES (1, 'joe@foo.com');
+INSERT INTO `users` (`id`, `email`) VALUES (2, 'leak@foo.com'
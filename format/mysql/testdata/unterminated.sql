@@ -0,0 +1,4 @@
+INSERT INTO `users` (`id`, `email`) VALUES (1,'it's broken@foo.com');
+INSERT INTO `users` (`id`, `email`) VALUES (2,'two@foo.com');
+INSERT INTO `users` (`id`, `email`) VALUES (3,'three@foo.com');
+INSERT INTO `users` (`id`, `email`) VALUES (4,'four@foo.com');
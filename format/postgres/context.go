@@ -0,0 +1,175 @@
+package postgres
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Matches the beginning of a CREATE TABLE statement up to the opening paren
+// of its column list; the table's (possibly qualified) name is captured.
+var reCreateTable = regexp.MustCompile(`(?i)CREATE\s+(?:(?:GLOBAL|LOCAL)\s+)?(?:(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?((?:"(?:[^"]|"")+"|[\w$]+)(?:\.(?:"(?:[^"]|"")+"|[\w$]+))*)\s*\(`)
+
+// Keywords that introduce a table constraint (rather than a column) in
+// the body of a CREATE TABLE statement.
+var constraintKeywords = map[string]bool{
+	"check":      true,
+	"constraint": true,
+	"exclude":    true,
+	"foreign":    true,
+	"like":       true,
+	"primary":    true,
+	"unique":     true,
+}
+
+// Context accumulates information about the structure of input data
+// which can later be used to scrub the same data.
+type Context struct {
+	TableColumns map[string][]string
+}
+
+// Scan parses CREATE TABLE statements from SQL text (typically the output
+// of `pg_dump --schema-only`) and records the column names of each table.
+func (ctx *Context) Scan(sql string) error {
+	for _, loc := range reCreateTable.FindAllStringSubmatchIndex(sql, -1) {
+		tableName := unqualify(sql[loc[2]:loc[3]])
+		body, ok := parenthesized(sql[loc[1]-1:])
+		if !ok {
+			continue
+		}
+		columns := make([]string, 0, 32)
+		for _, defn := range splitList(body) {
+			name, quoted := firstIdentifier(defn)
+			if name == "" || (!quoted && constraintKeywords[name]) {
+				continue
+			}
+			columns = append(columns, name)
+		}
+		ctx.TableColumns[tableName] = columns
+	}
+	return nil
+}
+
+func NewContext() *Context {
+	return &Context{
+		TableColumns: make(map[string][]string),
+	}
+}
+
+// Returns the contents of the parenthesized expression that begins at s[0],
+// respecting nested parens as well as quoted strings and identifiers.
+func parenthesized(s string) (string, bool) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s[1:i], true
+			}
+		}
+	}
+	return "", false
+}
+
+// Splits a comma-separated list, ignoring commas that appear within parens
+// or quotes.
+func splitList(s string) []string {
+	items := make([]string, 0, 16)
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+// Returns the identifier at the beginning of s, normalized the same way
+// that PostgreSQL does: quoted identifiers are taken literally, while
+// unquoted identifiers are folded to lower case.
+func firstIdentifier(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, `"`) {
+		for i := 1; i < len(s); i++ {
+			if s[i] == '"' {
+				if i+1 < len(s) && s[i+1] == '"' {
+					i++
+					continue
+				}
+				return strings.ReplaceAll(s[1:i], `""`, `"`), true
+			}
+		}
+		return "", true
+	}
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(r == '_' || r == '$' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7f)
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	return strings.ToLower(s[:end]), false
+}
+
+// Strips the schema from a qualified name such as `public.users`, returning
+// only the normalized table name.
+func unqualify(qualified string) string {
+	var name string
+	for rest := qualified; rest != ""; {
+		name, _ = firstIdentifier(rest)
+		dot := nextDot(rest)
+		if dot < 0 {
+			break
+		}
+		rest = rest[dot+1:]
+	}
+	return name
+}
+
+// Returns the index of the first '.' in s that is not within quotes.
+func nextDot(s string) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case '.':
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matches the header of a COPY data block as emitted by pg_dump, capturing
+// the (possibly qualified) table name and the optional column list.
+var reCopyHeader = regexp.MustCompile(`(?i)^COPY\s+((?:"(?:[^"]|"")+"|[\w$]+)(?:\.(?:"(?:[^"]|"")+"|[\w$]+))*)\s*(?:\(([^)]*)\))?\s+FROM\s+stdin\b`)
+
+// Matches the text representation of a number (integer, numeric or float).
+var reNumber = regexp.MustCompile(`^[-+]?(\d+(\.\d*)?|\.\d+)([eE][-+]?\d+)?$`)
+
+// Null is the COPY text-format representation of NULL.
+const Null = `\N`
+
+// Copy describes a COPY ... FROM stdin block.
+type Copy struct {
+	// Name of the table being copied into.
+	TableName string
+	// List of column names (explicitly specified in the COPY header, or inferred from table schema).
+	ColumnNames []string
+}
+
+// Parses a COPY header; returns nil if the line is not a COPY header.
+func parseCopy(ctx *Context, line string) *Copy {
+	m := reCopyHeader.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+
+	cp := &Copy{TableName: unqualify(m[1])}
+	if m[2] != "" {
+		for _, col := range splitList(m[2]) {
+			name, _ := firstIdentifier(col)
+			cp.ColumnNames = append(cp.ColumnNames, name)
+		}
+	} else {
+		// Column names were omitted from the COPY header; infer them from the previously-scanned table schema.
+		cp.ColumnNames = ctx.TableColumns[cp.TableName]
+	}
+	return cp
+}
+
+// Names returns a list of column names that apply to the colIdx'th field
+// of a row. The list contains 1-3 elements depending on the completeness of
+// the schema information available.
+func (cp *Copy) Names(colIdx int) []string {
+	names := make([]string, 0, 3)
+	if colIdx < len(cp.ColumnNames) {
+		colName := cp.ColumnNames[colIdx]
+		names = append(names, colName)
+		names = append(names, fmt.Sprintf("%s.%s", cp.TableName, colName))
+	}
+	names = append(names, fmt.Sprintf("%s.%d", cp.TableName, colIdx))
+	return names
+}
+
+// Returns the name under which to report an unclassified field: its
+// "table.column" name if known, or else its "table.index" name.
+func classifiedName(names []string) string {
+	if len(names) == 3 {
+		return names[1]
+	}
+	return names[len(names)-1]
+}
+
+// Returns true if line terminates a COPY data block.
+func isCopyEnd(line string) bool {
+	return strings.TrimRight(line, "\r\n") == `\.`
+}
+
+// Splits a COPY data row into its (still escaped) fields and line ending.
+func splitRow(line string) ([]string, string) {
+	row := strings.TrimRight(line, "\r\n")
+	return strings.Split(row, "\t"), line[len(row):]
+}
+
+// Returns the decimal representation of a field that holds a number, or
+// false if it holds something else. COPY data is untyped, so any field that
+// looks like a number is treated as one. Exponents are expanded so that
+// masking the digits cannot change the magnitude of the number.
+func numberField(s string) (string, bool) {
+	if !reNumber.MatchString(s) {
+		return "", false
+	}
+	if strings.IndexAny(s, "eE") < 0 {
+		return s, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return "", false
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), true
+}
+
+// Decodes a field of COPY text-format data, interpreting backslash escapes.
+// The caller must check for Null before decoding.
+func decodeField(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch c = s[i]; c {
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'v':
+			sb.WriteByte('\v')
+		case 'x':
+			var v byte
+			n := 0
+			for ; n < 2 && i+1 < len(s) && isHex(s[i+1]); n++ {
+				i++
+				v = v<<4 | unhex(s[i])
+			}
+			if n == 0 {
+				sb.WriteByte('x')
+			} else {
+				sb.WriteByte(v)
+			}
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			v := c - '0'
+			for n := 1; n < 3 && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '7'; n++ {
+				i++
+				v = v<<3 | (s[i] - '0')
+			}
+			sb.WriteByte(v)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// Encodes a value as a field of COPY text-format data.
+func encodeField(s string) string {
+	if strings.IndexAny(s, "\\\b\f\n\r\t\v") < 0 {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\v':
+			sb.WriteString(`\v`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	default:
+		return c - '0'
+	}
+}
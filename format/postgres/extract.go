@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"io"
+)
+
+func extract(names []string, line Line) []string {
+	values := []string{}
+	if line.Copy == nil {
+		return values
+	}
+
+	fields, _ := splitRow(line.Text)
+	for i, field := range fields {
+		if field != Null && matchFieldName(names, line.Copy.Names(i)) {
+			values = append(values, decodeField(field))
+		}
+	}
+	return values
+}
+
+// Extract prints the value of every COPY field whose name matches one of
+// names, one value per line.
+func Extract(ctx *Context, names []string, r io.Reader, w io.Writer) {
+	reader := NewReader(ctx, r)
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			break
+		}
+		for _, v := range extract(names, line) {
+			w.Write([]byte(v + "\n"))
+		}
+	}
+}
+
+func matchFieldName(want, got []string) bool {
+	for _, w := range want {
+		for _, g := range got {
+			if w == g {
+				return true
+			}
+		}
+	}
+	return false
+}
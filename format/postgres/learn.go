@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"github.com/xeger/pipeclean/nlp"
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+func learn(models map[string]nlp.Model, policy *scrubbing.Policy, line Line) {
+	if line.Copy == nil {
+		return
+	}
+
+	fields, _ := splitRow(line.Text)
+	for i, field := range fields {
+		if field == Null {
+			continue
+		}
+		disposition, _ := policy.MatchFieldName(line.Copy.Names(i))
+		disposition = policy.FirstStep(disposition)
+		switch disposition.Action() {
+		case "generate":
+			model := models[disposition.Parameter()]
+			if model != nil {
+				model.Train(decodeField(field))
+			}
+		}
+	}
+}
+
+// LearnChan trains models using COPY data from a sequence of lines produced
+// by a Reader. Models are not safe for concurrent training, so the caller
+// must not share them between several LearnChan goroutines.
+func LearnChan(models map[string]nlp.Model, policy *scrubbing.Policy, in <-chan Line) {
+	for line := range in {
+		learn(models, policy, line)
+	}
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+)
+
+// Matches the beginning of an INSERT statement.
+var reInsert = regexp.MustCompile(`(?i)^INSERT\s+INTO\b`)
+
+// Line is a single line of pg_dump output.
+type Line struct {
+	// Copy is the COPY block to which this line belongs, or nil if the line
+	// is not COPY data (e.g. it is DDL, a comment, or the COPY header itself).
+	Copy *Copy
+	// Text is the content of the line, including its line terminator.
+	Text string
+	// Insert is true if the line begins an INSERT statement (as emitted by
+	// `pg_dump --inserts`). Only COPY data is scrubbed, so the statement's
+	// values pass through unchanged.
+	Insert bool
+}
+
+// Reader splits a pg_dump stream into lines and annotates each line with
+// the COPY block (if any) that it belongs to. Because COPY data is only
+// meaningful in light of the header that precedes it, the stream must be
+// read sequentially; however, the resulting lines are self-contained and
+// may be processed in parallel.
+type Reader struct {
+	ctx  *Context
+	br   *bufio.Reader
+	copy *Copy
+}
+
+func NewReader(ctx *Context, r io.Reader) *Reader {
+	return &Reader{ctx: ctx, br: bufio.NewReader(r)}
+}
+
+// ReadLine returns the next line of input; it returns io.EOF after the
+// final line has been read.
+func (r *Reader) ReadLine() (Line, error) {
+	text, err := r.br.ReadString('\n')
+	if err != nil && (err != io.EOF || text == "") {
+		return Line{}, err
+	}
+
+	if r.copy != nil {
+		if isCopyEnd(text) {
+			r.copy = nil
+			return Line{Text: text}, nil
+		}
+		return Line{Copy: r.copy, Text: text}, nil
+	}
+
+	r.copy = parseCopy(r.ctx, text)
+	return Line{Text: text, Insert: reInsert.MatchString(text)}, nil
+}
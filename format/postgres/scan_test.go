@@ -0,0 +1,26 @@
+package postgres_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xeger/pipeclean/format/postgres"
+)
+
+func scan(input string) *postgres.Context {
+	ctx := postgres.NewContext()
+	ctx.Scan(input)
+	return ctx
+}
+
+func TestScanCreateTables(t *testing.T) {
+	input := read(t, "create_tables.sql")
+	ctx := scan(input)
+
+	expected := map[string][]string{
+		"users": {"id", "email", "Nickname", "balance", "created_at"},
+	}
+	if !reflect.DeepEqual(ctx.TableColumns, expected) {
+		t.Errorf("TableColumns scan failed: expected %v, got %v", expected, ctx.TableColumns)
+	}
+}
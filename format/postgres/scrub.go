@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"strings"
+
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+func scrub(sc *scrubbing.Scrubber, line Line) string {
+	if line.Copy == nil {
+		return line.Text
+	}
+
+	fields, eol := splitRow(line.Text)
+	if sc.Policy().UsesRows() {
+		row := make(scrubbing.Row, 3*len(fields))
+		for i, field := range fields {
+			if field != Null {
+				row.Set(line.Copy.Names(i), decodeField(field))
+			} else {
+				row.SetNull(line.Copy.Names(i))
+			}
+		}
+		sc = sc.WithRow(row)
+	}
+	for i, field := range fields {
+		names := line.Copy.Names(i)
+		sc.Classifier.Check(classifiedName(names), names)
+		if field == Null {
+			continue
+		}
+		if n, ok := numberField(field); ok {
+			// numbers are matched like numeric SQL values in other modes, so
+			// that e.g. an "email" rule does not apply to email_addresses.id
+			if out := sc.ScrubNumber(n, names); out == "" {
+				fields[i] = Null
+			} else if out != n {
+				fields[i] = encodeField(out)
+			}
+			continue
+		}
+		s := decodeField(field)
+		if sc.EraseString(s, names) {
+			fields[i] = Null
+		} else {
+			fields[i] = encodeField(sc.ScrubString(s, names))
+		}
+	}
+	return strings.Join(fields, "\t") + eol
+}
+
+// ScrubChan sanitizes a sequence of lines produced by a Reader. It sends
+// one output string for every input line received, which allows the caller
+// to handle parallelism as desired.
+func ScrubChan(sc *scrubbing.Scrubber, in <-chan Line, out chan<- string) {
+	for line := range in {
+		out <- scrub(sc, line)
+	}
+}
@@ -0,0 +1,151 @@
+package postgres_test
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/xeger/pipeclean/format/postgres"
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+func read(t *testing.T, name string) string {
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("Failed to read test file %s: %s", name, err)
+	}
+	return string(data)
+}
+
+func scrub(ctx *postgres.Context, input string) string {
+	return scrubPolicy(ctx, input, scrubbing.DefaultPolicy())
+}
+
+func scrubPolicy(ctx *postgres.Context, input string, policy *scrubbing.Policy) string {
+	reader := postgres.NewReader(ctx, bytes.NewBufferString(input))
+	in := make(chan postgres.Line)
+	out := make(chan string)
+	output := new(strings.Builder)
+
+	scrubber := scrubbing.NewScrubber("", false, policy, nil)
+	go postgres.ScrubChan(scrubber, in, out)
+
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			break
+		}
+		in <- line
+		output.WriteString(<-out)
+	}
+	close(in)
+	close(out)
+
+	return output.String()
+}
+
+func TestCopy(t *testing.T) {
+	input := read(t, "copy.sql")
+	output := scrub(postgres.NewContext(), input)
+
+	if strings.Index(output, "COPY public.users (id, email, \"Nickname\", balance, created_at) FROM stdin;\n") < 0 {
+		t.Errorf("COPY header is missing")
+	}
+	if strings.Index(output, "1\tjyv@iws.com\tJoe\\tSchmoe\t10.00\t2021-03-04 05:06:07\n") < 0 {
+		t.Errorf("COPY row not properly sanitized")
+	}
+	if strings.Index(output, "2\t\\N\t\\N\t\\N\t2021-03-04 05:06:07\n") < 0 {
+		t.Errorf("COPY row with NULLs not preserved")
+	}
+	if strings.Index(output, "\\.\n") < 0 {
+		t.Errorf("COPY terminator is missing")
+	}
+	if strings.Index(output, "ADD CONSTRAINT users_pkey PRIMARY KEY (id);") < 0 {
+		t.Errorf("DDL after COPY block is missing")
+	}
+}
+
+func TestCopyEscapes(t *testing.T) {
+	input := read(t, "copy.sql")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^users\.Nickname$`), Out: "replace(a\tb\\c)"},
+		},
+	}
+	output := scrubPolicy(postgres.NewContext(), input, policy)
+
+	if strings.Index(output, "1\tjoe@foo.com\ta\\tb\\\\c\t") < 0 {
+		t.Errorf("COPY field not properly escaped")
+	}
+}
+
+func TestCopyNumbers(t *testing.T) {
+	input := "COPY public.email_addresses (id, email, phone) FROM stdin;\n12345\tjoe@foo.com\t8055551212\n\\.\n"
+	policy := scrubbing.DefaultPolicy()
+	policy.FieldName = append(policy.FieldName, scrubbing.FieldNameRule{In: regexp.MustCompile(`phone`), Out: "mask"})
+	output := scrubPolicy(postgres.NewContext(), input, policy)
+
+	m := regexp.MustCompile(`\n(\d+)\t([^\t]+)\t(\d+)\n`).FindStringSubmatch(output)
+	if m == nil {
+		t.Fatalf("COPY row not properly sanitized: %q", output)
+	}
+	if m[1] != "12345" {
+		t.Errorf("email rule applied to email_addresses.id: %s", m[1])
+	}
+	if m[2] == "joe@foo.com" {
+		t.Errorf("email not scrubbed")
+	}
+	if m[3] == "8055551212" || len(m[3]) != 10 {
+		t.Errorf("phone not properly masked: %s", m[3])
+	}
+}
+
+func TestInserts(t *testing.T) {
+	reader := postgres.NewReader(postgres.NewContext(), strings.NewReader("SET client_encoding = 'UTF8';\nINSERT INTO public.users VALUES (1, 'joe@foo.com');\n"))
+	for _, want := range []bool{false, true} {
+		line, err := reader.ReadLine()
+		if err != nil {
+			t.Fatalf("ReadLine() returned %v", err)
+		}
+		if line.Insert != want {
+			t.Errorf("Insert = %v for %q", line.Insert, line.Text)
+		}
+	}
+}
+
+func TestCopyPositional(t *testing.T) {
+	input := read(t, "copy-positional.sql")
+
+	ctx := postgres.NewContext()
+	if err := ctx.Scan(read(t, "create_tables.sql")); err != nil {
+		t.Errorf("Scan failed: %s", err)
+	}
+
+	output := scrub(ctx, input)
+
+	if strings.Index(output, "1\tjyv@iws.com\t\\N\t\\N\t2021-03-04 05:06:07\n") < 0 {
+		t.Errorf("COPY row not properly sanitized")
+	}
+}
+
+func TestCopyPositionalNoScan(t *testing.T) {
+	input := read(t, "copy-positional.sql")
+
+	// output may not be useful, but it shouldn't crash if there are no column names to work with!
+	output := scrub(postgres.NewContext(), input)
+	if output != input {
+		t.Errorf("COPY row unexpectedly changed without context")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	input := read(t, "copy.sql")
+	output := new(strings.Builder)
+	postgres.Extract(postgres.NewContext(), []string{"users.Nickname"}, strings.NewReader(input), output)
+
+	if got, want := output.String(), "Joe\tSchmoe\n"; got != want {
+		t.Errorf("Extract() = %q, want %q", got, want)
+	}
+}
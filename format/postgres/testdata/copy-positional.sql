@@ -0,0 +1,3 @@
+COPY users FROM stdin;
+1	joe@foo.com	\N	\N	2021-03-04 05:06:07
+\.
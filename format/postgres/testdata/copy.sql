@@ -0,0 +1,16 @@
+--
+-- Data for Name: users; Type: TABLE DATA; Schema: public; Owner: postgres
+--
+
+COPY public.users (id, email, "Nickname", balance, created_at) FROM stdin;
+1	joe@foo.com	Joe\tSchmoe	10.00	2021-03-04 05:06:07
+2	\N	\N	\N	2021-03-04 05:06:07
+\.
+
+
+--
+-- Name: users users_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
@@ -0,0 +1,21 @@
+--
+-- PostgreSQL database dump
+--
+
+SET statement_timeout = 0;
+SET client_encoding = 'UTF8';
+
+--
+-- Name: users; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.users (
+    id bigint NOT NULL,
+    email character varying(255) DEFAULT ''::character varying NOT NULL,
+    "Nickname" text,
+    balance numeric(10,2),
+    created_at timestamp(6) without time zone NOT NULL,
+    CONSTRAINT users_balance_check CHECK ((balance >= (0)::numeric))
+);
+
+ALTER TABLE public.users OWNER TO postgres;
@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Matches the beginning of a CREATE TABLE statement up to the opening paren
+// of its column list; the table's (possibly qualified) name is captured.
+var reCreateTable = regexp.MustCompile(`(?i)CREATE\s+(?:(?:TEMP|TEMPORARY)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?((?:(?:"(?:[^"]|"")+"|\[[^\]]+\]|` + "`(?:[^`]|``)+`" + `|[\w$]+)\s*\.\s*)?(?:"(?:[^"]|"")+"|\[[^\]]+\]|` + "`(?:[^`]|``)+`" + `|[\w$]+))\s*\(`)
+
+// Keywords that introduce a table constraint (rather than a column) in
+// the body of a CREATE TABLE statement.
+var constraintKeywords = map[string]bool{
+	"check":      true,
+	"constraint": true,
+	"foreign":    true,
+	"primary":    true,
+	"unique":     true,
+}
+
+// Context accumulates information about the structure of input data
+// which can later be used to scrub the same data.
+type Context struct {
+	TableColumns map[string][]string
+
+	// Guards TableColumns, which the Reader updates as it encounters CREATE
+	// TABLE statements while other goroutines scrub INSERTs.
+	mx sync.RWMutex
+}
+
+// Scan parses CREATE TABLE statements from SQL text (typically the output
+// of `sqlite3 db .schema` or `.dump`) and records the column names of each table.
+func (ctx *Context) Scan(sql string) error {
+	ctx.mx.Lock()
+	defer ctx.mx.Unlock()
+	for _, loc := range reCreateTable.FindAllStringSubmatchIndex(sql, -1) {
+		tableName := unqualify(sql[loc[2]:loc[3]])
+		lx := &lexer{s: sql, pos: loc[1] - 1}
+		body, ok := lx.parenthesized()
+		if !ok {
+			continue
+		}
+		columns := make([]string, 0, 32)
+		for _, defn := range splitList(body) {
+			dl := &lexer{s: defn}
+			name, quoted, ok := dl.identifier()
+			if !ok || (!quoted && constraintKeywords[name]) {
+				continue
+			}
+			columns = append(columns, name)
+		}
+		ctx.TableColumns[tableName] = columns
+	}
+	return nil
+}
+
+// Returns the column names of a table, if known.
+func (ctx *Context) columns(tableName string) []string {
+	ctx.mx.RLock()
+	defer ctx.mx.RUnlock()
+	return ctx.TableColumns[tableName]
+}
+
+func NewContext() *Context {
+	return &Context{
+		TableColumns: make(map[string][]string),
+	}
+}
+
+// Splits a comma-separated list, ignoring commas that appear within parens
+// or quotes.
+func splitList(s string) []string {
+	items := make([]string, 0, 16)
+	lx := &lexer{s: s}
+	start := 0
+	for !lx.eof() {
+		switch lx.peek() {
+		case ',':
+			items = append(items, strings.TrimSpace(s[start:lx.pos]))
+			lx.pos++
+			start = lx.pos
+		case '(':
+			if _, ok := lx.parenthesized(); !ok {
+				lx.pos = len(s)
+			}
+		default:
+			lx.skipToken()
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+// Strips the schema from a qualified name such as `main.users`, returning
+// only the normalized table name.
+func unqualify(qualified string) string {
+	lx := &lexer{s: qualified}
+	name, _, _ := lx.identifier()
+	for lx.skipSpace(); !lx.eof() && lx.peek() == '.'; lx.skipSpace() {
+		lx.pos++
+		name, _, _ = lx.identifier()
+	}
+	return name
+}
@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"io"
+)
+
+func extract(ctx *Context, names []string, stmt string) []string {
+	values := []string{}
+	ins, ok := parseInsert(ctx, stmt)
+	if !ok {
+		return values
+	}
+
+	for _, row := range ins.rows {
+		for i, v := range row {
+			if v.kind == kindString && matchFieldName(names, ins.Names(i)) {
+				values = append(values, v.data)
+			}
+		}
+	}
+	return values
+}
+
+// Extract prints the value of every string field whose name matches one of
+// names, one value per line.
+func Extract(ctx *Context, names []string, r io.Reader, w io.Writer) {
+	reader := NewReader(ctx, r)
+	for {
+		stmt, err := reader.ReadStatement()
+		if err != nil {
+			break
+		}
+		for _, v := range extract(ctx, names, stmt) {
+			w.Write([]byte(v + "\n"))
+		}
+	}
+}
+
+func matchFieldName(want, got []string) bool {
+	for _, w := range want {
+		for _, g := range got {
+			if w == g {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,348 @@
+package sqlite
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type valueKind int
+
+const (
+	// An expression that pipeclean does not interpret (function call, etc).
+	kindOther valueKind = iota
+	// The NULL literal.
+	kindNull
+	// A numeric literal.
+	kindNumber
+	// A string literal, or an expression that evaluates to a constant string.
+	kindString
+	// A blob literal (X'...').
+	kindBlob
+)
+
+// Value is a single element of an INSERT tuple.
+type value struct {
+	// Location of the value's SQL text within the statement.
+	start, end int
+	kind       valueKind
+	// The decoded string or blob content of the value, or the decimal
+	// representation of a number.
+	data string
+}
+
+// Insert is a parsed INSERT statement.
+type insert struct {
+	// Name of the table being inserted into.
+	tableName string
+	// List of column names (explicitly specified in current statement, or inferred from table schema).
+	columnNames []string
+	// Value tuples of the current statement.
+	rows [][]value
+}
+
+// Parses an INSERT statement. Returns false if stmt is not an INSERT, or
+// if it could not be understood.
+func parseInsert(ctx *Context, stmt string) (*insert, bool) {
+	lx := &lexer{s: stmt}
+	if !lx.keyword("INSERT") {
+		return nil, false
+	}
+	if lx.keyword("OR") {
+		lx.identifier()
+	}
+	if !lx.keyword("INTO") {
+		return nil, false
+	}
+
+	ins := &insert{}
+	name, _, ok := lx.identifier()
+	if !ok {
+		return nil, false
+	}
+	if lx.punct('.') {
+		if name, _, ok = lx.identifier(); !ok {
+			return nil, false
+		}
+	}
+	ins.tableName = name
+
+	lx.skipSpace()
+	if !lx.eof() && lx.peek() == '(' {
+		cols, ok := lx.parenthesized()
+		if !ok {
+			return nil, false
+		}
+		for _, col := range splitList(cols) {
+			name, _, _ := (&lexer{s: col}).identifier()
+			ins.columnNames = append(ins.columnNames, name)
+		}
+	} else {
+		// Column names were omitted from the INSERT; infer them from the previously-scanned table schema.
+		ins.columnNames = ctx.columns(ins.tableName)
+	}
+
+	if !lx.keyword("VALUES") {
+		return nil, false
+	}
+	for {
+		if !lx.punct('(') {
+			return nil, false
+		}
+		row := make([]value, 0, len(ins.columnNames))
+		for {
+			start, end, ok := lx.expression()
+			if !ok {
+				return nil, false
+			}
+			row = append(row, evalValue(stmt, start, end))
+			if lx.punct(')') {
+				break
+			} else if !lx.punct(',') {
+				return nil, false
+			}
+		}
+		ins.rows = append(ins.rows, row)
+		if !lx.punct(',') {
+			break
+		}
+	}
+	if !lx.punct(';') {
+		return nil, false
+	}
+	lx.skipSpace()
+	return ins, lx.eof()
+}
+
+// Names returns a list of column names that apply to the colIdx'th value
+// of a row. The list contains 1-3 elements depending on the completeness of
+// the schema information available.
+func (ins *insert) Names(colIdx int) []string {
+	names := make([]string, 0, 3)
+	if colIdx < len(ins.columnNames) {
+		colName := ins.columnNames[colIdx]
+		names = append(names, colName)
+		names = append(names, fmt.Sprintf("%s.%s", ins.tableName, colName))
+	}
+	names = append(names, fmt.Sprintf("%s.%d", ins.tableName, colIdx))
+	return names
+}
+
+// Returns the name under which to report an unclassified field: its
+// "table.column" name if known, or else its "table.index" name.
+func classifiedName(names []string) string {
+	if len(names) == 3 {
+		return names[1]
+	}
+	return names[len(names)-1]
+}
+
+// Classifies the SQL expression at stmt[start:end] and decodes its content.
+func evalValue(stmt string, start, end int) value {
+	v := value{start: start, end: end}
+	text := stmt[start:end]
+
+	if strings.EqualFold(text, "NULL") {
+		v.kind = kindNull
+		return v
+	}
+
+	lx := &lexer{s: text}
+	if (lx.peek() == 'X' || lx.peek() == 'x') && len(text) > 1 && text[1] == '\'' {
+		lx.pos++
+		if h, ok := lx.stringLiteral(); ok && lx.eof() {
+			if b, err := hex.DecodeString(h); err == nil {
+				v.kind, v.data = kindBlob, string(b)
+			}
+		}
+		return v
+	}
+
+	if s, ok := numberLiteral(strings.TrimSpace(text)); ok {
+		v.kind, v.data = kindNumber, s
+		return v
+	}
+
+	if s, ok := lx.stringExpr(); ok && lx.eof() {
+		v.kind, v.data = kindString, s
+	}
+	return v
+}
+
+var reNumber = regexp.MustCompile(`^[-+]?(\d+(\.\d*)?|\.\d+)([eE][-+]?\d+)?$`)
+
+// Returns the decimal representation of a numeric literal, or false if text
+// is not one. Exponents are expanded so that masking the digits cannot
+// change the magnitude of the number.
+func numberLiteral(text string) (string, bool) {
+	if !reNumber.MatchString(text) {
+		return "", false
+	}
+	if strings.IndexAny(text, "eE") < 0 {
+		return text, true
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return "", false
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), true
+}
+
+// Evaluates a constant string expression, which may be a literal or a
+// combination of the functions that various versions of sqlite3 use to
+// represent control characters: replace(), char(), unistr() and ||.
+func (lx *lexer) stringExpr() (string, bool) {
+	var sb strings.Builder
+	for {
+		s, ok := lx.stringTerm()
+		if !ok {
+			return "", false
+		}
+		sb.WriteString(s)
+		lx.skipSpace()
+		if !strings.HasPrefix(lx.s[lx.pos:], "||") {
+			return sb.String(), true
+		}
+		lx.pos += 2
+	}
+}
+
+func (lx *lexer) stringTerm() (string, bool) {
+	lx.skipSpace()
+	if lx.eof() {
+		return "", false
+	}
+	if lx.peek() == '\'' {
+		return lx.stringLiteral()
+	}
+
+	switch {
+	case lx.keyword("replace"):
+		var args [3]string
+		if !lx.punct('(') {
+			return "", false
+		}
+		for i := range args {
+			s, ok := lx.stringExpr()
+			if !ok || (i < 2 && !lx.punct(',')) {
+				return "", false
+			}
+			args[i] = s
+		}
+		if !lx.punct(')') || args[1] == "" {
+			return "", false
+		}
+		return strings.ReplaceAll(args[0], args[1], args[2]), true
+	case lx.keyword("char"):
+		var sb strings.Builder
+		if !lx.punct('(') {
+			return "", false
+		}
+		for {
+			lx.skipSpace()
+			start := lx.pos
+			for !lx.eof() && lx.peek() >= '0' && lx.peek() <= '9' {
+				lx.pos++
+			}
+			n, err := strconv.Atoi(lx.s[start:lx.pos])
+			if err != nil {
+				return "", false
+			}
+			sb.WriteRune(rune(n))
+			if lx.punct(')') {
+				return sb.String(), true
+			} else if !lx.punct(',') {
+				return "", false
+			}
+		}
+	case lx.keyword("unistr"):
+		if !lx.punct('(') {
+			return "", false
+		}
+		s, ok := lx.stringLiteral()
+		if !ok || !lx.punct(')') {
+			return "", false
+		}
+		return unistr(s)
+	}
+	return "", false
+}
+
+// Interprets the escape sequences understood by SQLite's unistr() function.
+func unistr(s string) (string, bool) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		rest := s[i+1:]
+		var digits int
+		switch {
+		case strings.HasPrefix(rest, `\`):
+			sb.WriteByte('\\')
+			i++
+			continue
+		case strings.HasPrefix(rest, "u"):
+			rest, digits = rest[1:], 4
+			i++
+		case strings.HasPrefix(rest, "+"):
+			rest, digits = rest[1:], 6
+			i++
+		case strings.HasPrefix(rest, "U"):
+			rest, digits = rest[1:], 8
+			i++
+		default:
+			digits = 4
+		}
+		if len(rest) < digits {
+			return "", false
+		}
+		r, err := strconv.ParseUint(rest[:digits], 16, 32)
+		if err != nil {
+			return "", false
+		}
+		sb.WriteRune(rune(r))
+		i += digits
+	}
+	return sb.String(), true
+}
+
+// Quote encodes s as an SQL expression. Control characters are expressed
+// with char() so that the statement remains on a single line and can be
+// restored by any version of sqlite3.
+func quote(s string) string {
+	pieces := make([]string, 0, 1)
+	var sb strings.Builder
+	var ctl []string
+	flush := func() {
+		if sb.Len() > 0 {
+			pieces = append(pieces, "'"+strings.ReplaceAll(sb.String(), "'", "''")+"'")
+			sb.Reset()
+		}
+		if len(ctl) > 0 {
+			pieces = append(pieces, "char("+strings.Join(ctl, ",")+")")
+			ctl = ctl[:0]
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c == 0x7f {
+			if sb.Len() > 0 {
+				flush()
+			}
+			ctl = append(ctl, strconv.Itoa(int(c)))
+		} else {
+			if len(ctl) > 0 {
+				flush()
+			}
+			sb.WriteByte(c)
+		}
+	}
+	flush()
+	if len(pieces) == 0 {
+		return "''"
+	}
+	return strings.Join(pieces, "||")
+}
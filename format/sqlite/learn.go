@@ -0,0 +1,39 @@
+package sqlite
+
+import (
+	"github.com/xeger/pipeclean/nlp"
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+func learn(ctx *Context, models map[string]nlp.Model, policy *scrubbing.Policy, stmt string) {
+	ins, ok := parseInsert(ctx, stmt)
+	if !ok {
+		return
+	}
+
+	for _, row := range ins.rows {
+		for i, v := range row {
+			if v.kind != kindString {
+				continue
+			}
+			disposition, _ := policy.MatchFieldName(ins.Names(i))
+			disposition = policy.FirstStep(disposition)
+			switch disposition.Action() {
+			case "generate":
+				model := models[disposition.Parameter()]
+				if model != nil {
+					model.Train(v.data)
+				}
+			}
+		}
+	}
+}
+
+// LearnChan trains models using INSERT statements produced by a Reader.
+// Models are not safe for concurrent training, so the caller must not share
+// them between several LearnChan goroutines.
+func LearnChan(ctx *Context, models map[string]nlp.Model, policy *scrubbing.Policy, in <-chan string) {
+	for stmt := range in {
+		learn(ctx, models, policy, stmt)
+	}
+}
@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Lexer is a minimal, forgiving tokenizer for the dialect of SQL that is
+// produced by `sqlite3 .dump`.
+type lexer struct {
+	s   string
+	pos int
+}
+
+func (lx *lexer) eof() bool {
+	return lx.pos >= len(lx.s)
+}
+
+func (lx *lexer) peek() byte {
+	return lx.s[lx.pos]
+}
+
+func (lx *lexer) skipSpace() {
+	for !lx.eof() && unicode.IsSpace(rune(lx.peek())) {
+		lx.pos++
+	}
+}
+
+// Consumes the given character (after optional whitespace) if present.
+func (lx *lexer) punct(c byte) bool {
+	lx.skipSpace()
+	if !lx.eof() && lx.peek() == c {
+		lx.pos++
+		return true
+	}
+	return false
+}
+
+// Consumes the given (case-insensitive) keyword if present.
+func (lx *lexer) keyword(kw string) bool {
+	lx.skipSpace()
+	end := lx.pos + len(kw)
+	if end > len(lx.s) || !strings.EqualFold(lx.s[lx.pos:end], kw) {
+		return false
+	}
+	if end < len(lx.s) && isIdentChar(lx.s[end]) {
+		return false
+	}
+	lx.pos = end
+	return true
+}
+
+// Consumes an identifier, which may be bare or quoted in any of the styles
+// that SQLite accepts. Identifiers are case-insensitive in SQLite, so the
+// result is folded to lower case.
+func (lx *lexer) identifier() (name string, quoted bool, ok bool) {
+	lx.skipSpace()
+	if lx.eof() {
+		return "", false, false
+	}
+	switch c := lx.peek(); c {
+	case '"', '`':
+		s, ok := lx.quoted(c, c)
+		return strings.ToLower(s), true, ok
+	case '[':
+		s, ok := lx.quoted('[', ']')
+		return strings.ToLower(s), true, ok
+	default:
+		start := lx.pos
+		for !lx.eof() && isIdentChar(lx.peek()) {
+			lx.pos++
+		}
+		return strings.ToLower(lx.s[start:lx.pos]), false, lx.pos > start
+	}
+}
+
+// Consumes a single-quoted string literal and returns its value.
+func (lx *lexer) stringLiteral() (string, bool) {
+	lx.skipSpace()
+	if lx.eof() || lx.peek() != '\'' {
+		return "", false
+	}
+	return lx.quoted('\'', '\'')
+}
+
+// Consumes text delimited by open and close, where a doubled close
+// character stands for itself.
+func (lx *lexer) quoted(open, close byte) (string, bool) {
+	if lx.eof() || lx.peek() != open {
+		return "", false
+	}
+	var sb strings.Builder
+	for i := lx.pos + 1; i < len(lx.s); i++ {
+		if c := lx.s[i]; c != close {
+			sb.WriteByte(c)
+		} else if open == close && i+1 < len(lx.s) && lx.s[i+1] == close {
+			sb.WriteByte(c)
+			i++
+		} else {
+			lx.pos = i + 1
+			return sb.String(), true
+		}
+	}
+	lx.pos = len(lx.s)
+	return "", false
+}
+
+// Consumes one token without interpreting it: a quoted string or
+// identifier, or else a single character.
+func (lx *lexer) skipToken() {
+	switch c := lx.peek(); c {
+	case '\'', '"', '`':
+		lx.quoted(c, c)
+	case '[':
+		lx.quoted('[', ']')
+	default:
+		lx.pos++
+	}
+}
+
+// Consumes a parenthesized expression beginning at the current position,
+// respecting nested parens and quotes; returns the text between the parens.
+func (lx *lexer) parenthesized() (string, bool) {
+	if !lx.punct('(') {
+		return "", false
+	}
+	start, depth := lx.pos, 1
+	for !lx.eof() {
+		switch lx.peek() {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				lx.pos++
+				return lx.s[start : lx.pos-1], true
+			}
+		}
+		lx.skipToken()
+	}
+	return "", false
+}
+
+// Consumes an expression up to (but not including) the next comma or
+// close paren that is not nested within parens or quotes.
+func (lx *lexer) expression() (start, end int, ok bool) {
+	lx.skipSpace()
+	start = lx.pos
+	for !lx.eof() {
+		switch lx.peek() {
+		case ',', ')':
+			end = start + len(strings.TrimRightFunc(lx.s[start:lx.pos], unicode.IsSpace))
+			return start, end, end > start
+		case '(':
+			if _, ok := lx.parenthesized(); !ok {
+				return start, lx.pos, false
+			}
+		default:
+			lx.skipToken()
+		}
+	}
+	return start, lx.pos, false
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c > 0x7f
+}
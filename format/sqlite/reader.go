@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Matches the first line of a CREATE TRIGGER statement, whose body may
+// contain several semicolon-terminated statements.
+var reCreateTrigger = regexp.MustCompile(`(?i)^\s*CREATE\s+(?:(?:TEMP|TEMPORARY)\s+)?TRIGGER\b`)
+
+// Matches the final line of a CREATE TRIGGER statement.
+var reTriggerEnd = regexp.MustCompile(`(?i)\bEND\s*;\s*$`)
+
+// Reader splits a `sqlite3 .dump` stream into statements. Usually, each
+// line is a statement; however, older versions of sqlite3 emit string
+// literals that contain raw newlines, and triggers may span several lines.
+// Reader joins such lines so that every string it returns is complete.
+//
+// Reader also records the columns of each CREATE TABLE statement in ctx, so
+// that later INSERT statements of the same stream can be understood even
+// though they omit column names. Because of this, the stream must be read
+// sequentially; however, the resulting statements may be processed in
+// parallel.
+type Reader struct {
+	ctx *Context
+	br  *bufio.Reader
+}
+
+func NewReader(ctx *Context, r io.Reader) *Reader {
+	return &Reader{ctx: ctx, br: bufio.NewReader(r)}
+}
+
+// ReadStatement returns the next statement (or non-statement line) of
+// input, including its line terminator; it returns io.EOF after the final
+// statement has been read.
+func (r *Reader) ReadStatement() (string, error) {
+	stmt, err := r.readStatement()
+	if err == nil && (&lexer{s: stmt}).keyword("CREATE") && reCreateTable.MatchString(stmt) {
+		r.ctx.Scan(stmt)
+	}
+	return stmt, err
+}
+
+func (r *Reader) readStatement() (string, error) {
+	var sb strings.Builder
+	var quote byte
+	trigger := false
+
+	for {
+		line, err := r.br.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if sb.Len() > 0 {
+				return sb.String(), nil
+			}
+			return "", err
+		}
+		if sb.Len() == 0 {
+			trigger = reCreateTrigger.MatchString(line)
+		}
+		sb.WriteString(line)
+
+		for i := 0; i < len(line); i++ {
+			switch c := line[i]; {
+			case quote == 0 && (c == '\'' || c == '"' || c == '`'):
+				quote = c
+			case quote == 0 && c == '[':
+				quote = ']'
+			case c == quote:
+				quote = 0
+			}
+		}
+
+		if quote == 0 && (!trigger || reTriggerEnd.MatchString(line)) {
+			return sb.String(), nil
+		}
+		if err == io.EOF {
+			return sb.String(), nil
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package sqlite_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xeger/pipeclean/format/sqlite"
+)
+
+func scan(input string) *sqlite.Context {
+	ctx := sqlite.NewContext()
+	ctx.Scan(input)
+	return ctx
+}
+
+func TestScanCreateTables(t *testing.T) {
+	input := read(t, "dump.sql")
+	ctx := scan(input)
+
+	expected := map[string][]string{
+		"user data": {"id", "email", "bio", "avatar"},
+	}
+	if !reflect.DeepEqual(ctx.TableColumns, expected) {
+		t.Errorf("TableColumns scan failed: expected %v, got %v", expected, ctx.TableColumns)
+	}
+}
@@ -0,0 +1,132 @@
+package sqlite
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+func scrub(ctx *Context, sc *scrubbing.Scrubber, stmt string) string {
+	if reCreateTrigger.MatchString(stmt) {
+		return scrubTrigger(ctx, sc, stmt)
+	}
+	return scrubInsert(ctx, sc, stmt)
+}
+
+// Scrubs the INSERT statements in the body of a CREATE TRIGGER statement,
+// which would otherwise carry their values into output verbatim.
+func scrubTrigger(ctx *Context, sc *scrubbing.Scrubber, stmt string) string {
+	var sb strings.Builder
+	last := 0
+	lx := &lexer{s: stmt}
+	for !lx.eof() {
+		if !isIdentChar(lx.peek()) {
+			lx.skipToken()
+			continue
+		}
+		start := lx.pos
+		if word, _, _ := lx.identifier(); word != "insert" {
+			continue
+		}
+		// the body statement ends at the next semicolon outside of quotes
+		end := &lexer{s: stmt, pos: lx.pos}
+		for !end.eof() && end.peek() != ';' {
+			end.skipToken()
+		}
+		if end.eof() {
+			break
+		}
+		body := stmt[start : end.pos+1]
+		if _, ok := parseInsert(ctx, body); !ok {
+			// e.g. "AFTER INSERT ON"
+			continue
+		}
+		sb.WriteString(stmt[last:start])
+		sb.WriteString(scrubInsert(ctx, sc, body))
+		last, lx.pos = end.pos+1, end.pos+1
+	}
+	sb.WriteString(stmt[last:])
+	return sb.String()
+}
+
+func scrubInsert(ctx *Context, sc *scrubbing.Scrubber, stmt string) string {
+	ins, ok := parseInsert(ctx, stmt)
+	if !ok {
+		return stmt
+	}
+
+	var sb strings.Builder
+	last := 0
+	usesRows := sc.Policy().UsesRows()
+	for _, row := range ins.rows {
+		sc := sc
+		if usesRows {
+			values := make(scrubbing.Row, 3*len(row))
+			for i, v := range row {
+				switch v.kind {
+				case kindString, kindNumber:
+					values.Set(ins.Names(i), v.data)
+				case kindOther:
+					// e.g. a number
+					values.Set(ins.Names(i), strings.TrimSpace(stmt[v.start:v.end]))
+				default:
+					values.SetNull(ins.Names(i))
+				}
+			}
+			sc = sc.WithRow(values)
+		}
+		for i, v := range row {
+			names := ins.Names(i)
+			sc.Classifier.Check(classifiedName(names), names)
+			var replacement string
+			switch v.kind {
+			case kindString:
+				if sc.EraseString(v.data, names) {
+					replacement = "NULL"
+				} else if out := sc.ScrubString(v.data, names); out != v.data {
+					replacement = quote(out)
+				} else {
+					continue
+				}
+			case kindNumber:
+				out := sc.ScrubNumber(v.data, names)
+				if out == v.data {
+					continue
+				} else if out == "" {
+					replacement = "NULL"
+				} else if _, ok := numberLiteral(out); ok {
+					replacement = out
+				} else {
+					// e.g. a token
+					replacement = quote(out)
+				}
+			case kindBlob:
+				if b := sc.ScrubBytes([]byte(v.data), names); b == nil {
+					replacement = "NULL"
+				} else if string(b) != v.data {
+					replacement = "X'" + hex.EncodeToString(b) + "'"
+				} else {
+					continue
+				}
+			default:
+				continue
+			}
+			sb.WriteString(stmt[last:v.start])
+			sb.WriteString(replacement)
+			last = v.end
+		}
+	}
+	sb.WriteString(stmt[last:])
+
+	return sb.String()
+}
+
+// ScrubChan sanitizes a sequence of statements produced by a Reader. It
+// sends one output string for every input string received. This allows the
+// caller to handle parallelism as desired.
+func ScrubChan(ctx *Context, sc *scrubbing.Scrubber, in <-chan string, out chan<- string) {
+	for stmt := range in {
+		out <- scrub(ctx, sc, stmt)
+	}
+}
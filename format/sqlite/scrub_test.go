@@ -0,0 +1,131 @@
+package sqlite_test
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/xeger/pipeclean/format/sqlite"
+	"github.com/xeger/pipeclean/scrubbing"
+)
+
+func read(t *testing.T, name string) string {
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("Failed to read test file %s: %s", name, err)
+	}
+	return string(data)
+}
+
+func scrub(ctx *sqlite.Context, input string) string {
+	return scrubPolicy(ctx, input, scrubbing.DefaultPolicy())
+}
+
+func scrubPolicy(ctx *sqlite.Context, input string, policy *scrubbing.Policy) string {
+	reader := sqlite.NewReader(ctx, strings.NewReader(input))
+	in := make(chan string)
+	out := make(chan string)
+	output := new(strings.Builder)
+
+	scrubber := scrubbing.NewScrubber("", false, policy, nil)
+	go sqlite.ScrubChan(ctx, scrubber, in, out)
+
+	for {
+		stmt, err := reader.ReadStatement()
+		if err != nil {
+			break
+		}
+		in <- stmt
+		output.WriteString(<-out)
+	}
+	close(in)
+	close(out)
+
+	return output.String()
+}
+
+func TestInsert(t *testing.T) {
+	input := read(t, "dump.sql")
+	// column names come from the dump itself
+	output := scrub(sqlite.NewContext(), input)
+
+	if strings.Index(output, "CREATE TABLE IF NOT EXISTS \"user data\"") < 0 {
+		t.Errorf("CREATE TABLE statement is missing")
+	}
+	if strings.Index(output, "INSERT INTO \"user data\" VALUES(1,'jyv@iws.com',unistr('it''s\\u000ame'),X'0102');\n") < 0 {
+		t.Errorf("INSERT statement not properly sanitized")
+	}
+	if strings.Index(output, "INSERT INTO \"user data\" VALUES(2,'hruhlic@mzovvt.com',") < 0 {
+		t.Errorf("INSERT statement not properly sanitized")
+	}
+	if strings.Index(output, "'raw\nnewline',x'');\n") < 0 {
+		t.Errorf("multi-line INSERT statement not preserved")
+	}
+	if strings.Index(output, "CREATE TRIGGER tr after insert on \"user data\"\nbegin\n  insert into \"user data\" values(4,'") < 0 {
+		t.Errorf("CREATE TRIGGER statement not preserved")
+	}
+	if strings.Index(output, "trigger@foo.com") >= 0 {
+		t.Errorf("INSERT in CREATE TRIGGER statement not properly sanitized")
+	}
+	if strings.Index(output, "COMMIT;") < 0 {
+		t.Errorf("COMMIT statement is missing")
+	}
+}
+
+func TestInsertControlCharacters(t *testing.T) {
+	input := read(t, "dump.sql")
+	ctx := scan(input)
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^user data\.bio$`), Out: "replace(it's\r\nme)"},
+			{In: regexp.MustCompile(`^user data\.avatar$`), Out: "erase"},
+		},
+	}
+	output := scrubPolicy(ctx, input, policy)
+
+	if strings.Index(output, "VALUES(1,'joe@foo.com','it''s'||char(13,10)||'me',NULL);\n") < 0 {
+		t.Errorf("INSERT statement not properly encoded")
+	}
+	if strings.Index(output, "VALUES(3,'old@style.com','it''s'||char(13,10)||'me',NULL);\n") < 0 {
+		t.Errorf("multi-line INSERT statement not properly sanitized")
+	}
+}
+
+func TestInsertTyped(t *testing.T) {
+	input := "CREATE TABLE people(id integer primary key, phone integer, height real, badge blob);\n" +
+		"INSERT INTO people VALUES(1,8055551212,1.75e+2,X'deadbeef');\n"
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^people\.(phone|height|badge)$`), Out: "mask"},
+		},
+	}
+	output := scrubPolicy(scan(input), input, policy)
+
+	m := regexp.MustCompile(`VALUES\((\d+),(\d+),([\d.]+),X'([0-9a-f]+)'\);`).FindStringSubmatch(output)
+	if m == nil {
+		t.Fatalf("INSERT statement not properly sanitized: %s", output)
+	}
+	if m[1] != "1" {
+		t.Errorf("unscrubbed value was modified")
+	}
+	if m[2] == "8055551212" || len(m[2]) != 10 {
+		t.Errorf("number not properly masked: %s", m[2])
+	}
+	if m[3] == "175" || len(m[3]) != 3 {
+		t.Errorf("number with exponent not properly masked: %s", m[3])
+	}
+	if m[4] == "deadbeef" || len(m[4]) != 8 {
+		t.Errorf("blob not properly masked: %s", m[4])
+	}
+}
+
+func TestExtract(t *testing.T) {
+	input := read(t, "dump.sql")
+	output := new(strings.Builder)
+	sqlite.Extract(scan(input), []string{"bio"}, strings.NewReader(input), output)
+
+	if got, want := output.String(), "it's\nme\nline\nbreak\nraw\nnewline\n"; got != want {
+		t.Errorf("Extract() = %q, want %q", got, want)
+	}
+}
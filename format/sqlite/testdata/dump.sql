@@ -0,0 +1,12 @@
+PRAGMA foreign_keys=OFF;
+BEGIN TRANSACTION;
+CREATE TABLE IF NOT EXISTS "user data"(id integer primary key, email text, bio text, avatar blob, constraint x unique(email));
+INSERT INTO "user data" VALUES(1,'joe@foo.com',unistr('it''s\u000ame'),X'0102');
+INSERT INTO "user data" VALUES(2,'gophers@google.com',replace('line\nbreak','\n',char(10)),NULL);
+INSERT INTO "user data" VALUES(3,'old@style.com','raw
+newline',x'');
+CREATE TRIGGER tr after insert on "user data"
+begin
+  insert into "user data" values(4,'trigger@foo.com','b',null);
+end;
+COMMIT;
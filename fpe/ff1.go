@@ -0,0 +1,174 @@
+// Package fpe implements format-preserving encryption.
+package fpe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+)
+
+// Number of Feistel rounds prescribed by NIST SP 800-38G.
+const ff1Rounds = 10
+
+var (
+	ErrRadix  = errors.New("fpe: radix must be between 2 and 65536")
+	ErrLength = errors.New("fpe: input must have at least two numerals")
+	ErrDomain = errors.New("fpe: radix^length must be at least 1000000")
+)
+
+// FF1 implements the FF1 format-preserving encryption mode of NIST SP 800-38G
+// using AES. It encrypts numeral strings of a fixed radix, producing numeral
+// strings of the same radix and length.
+type FF1 struct {
+	block cipher.Block
+	radix int
+}
+
+// NewFF1 creates an FF1 cipher for the given radix. The key must be 16, 24
+// or 32 bytes long to select AES-128, AES-192 or AES-256.
+func NewFF1(key []byte, radix int) (*FF1, error) {
+	if radix < 2 || radix > 1<<16 {
+		return nil, ErrRadix
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &FF1{block: block, radix: radix}, nil
+}
+
+// Encrypt encrypts a numeral string (each element in [0, radix)).
+func (f *FF1) Encrypt(x []uint16, tweak []byte) ([]uint16, error) {
+	return f.cipher(x, tweak, true)
+}
+
+// Decrypt decrypts a numeral string produced by Encrypt with the same tweak.
+func (f *FF1) Decrypt(x []uint16, tweak []byte) ([]uint16, error) {
+	return f.cipher(x, tweak, false)
+}
+
+func (f *FF1) cipher(x []uint16, tweak []byte, encrypt bool) ([]uint16, error) {
+	n := len(x)
+	if n < 2 {
+		return nil, ErrLength
+	}
+	if math.Pow(float64(f.radix), float64(n)) < minDomain {
+		return nil, ErrDomain
+	}
+	u := n / 2
+	v := n - u
+	a, b := x[:u], x[u:]
+
+	// byte length of the numeric value of the longer half
+	bLen := int(math.Ceil(math.Ceil(float64(v)*math.Log2(float64(f.radix))) / 8))
+	dLen := 4*((bLen+3)/4) + 4
+
+	p := make([]byte, 16)
+	p[0], p[1], p[2] = 1, 2, 1
+	p[3], p[4], p[5] = byte(f.radix>>16), byte(f.radix>>8), byte(f.radix)
+	p[6] = 10
+	p[7] = byte(u)
+	binary.BigEndian.PutUint32(p[8:], uint32(n))
+	binary.BigEndian.PutUint32(p[12:], uint32(len(tweak)))
+
+	qLen := len(tweak) + bLen + 1
+	qLen += (16 - qLen%16) % 16
+	q := make([]byte, qLen)
+	copy(q, tweak)
+
+	radix := big.NewInt(int64(f.radix))
+	modU := new(big.Int).Exp(radix, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(radix, big.NewInt(int64(v)), nil)
+
+	for r := 0; r < ff1Rounds; r++ {
+		i := r
+		if !encrypt {
+			i = ff1Rounds - 1 - r
+		}
+		m, mod := u, modU
+		if i%2 == 1 {
+			m, mod = v, modV
+		}
+
+		// Q = T || 0* || [i] || [NUM(B)]^b, where B is the half being hashed
+		hashed := b
+		if !encrypt {
+			hashed = a
+		}
+		q[qLen-bLen-1] = byte(i)
+		num(hashed, radix).FillBytes(q[qLen-bLen:])
+
+		y := new(big.Int).SetBytes(f.prf(p, q, dLen))
+		c := num(a, radix)
+		if encrypt {
+			c.Add(c, y)
+		} else {
+			c = num(b, radix)
+			c.Sub(c, y)
+		}
+		c.Mod(c, mod)
+
+		if encrypt {
+			a, b = b, str(c, radix, m)
+		} else {
+			a, b = str(c, radix, m), a
+		}
+	}
+
+	out := make([]uint16, 0, n)
+	out = append(out, a...)
+	return append(out, b...), nil
+}
+
+// Computes the first d bytes of the expanded CBC-MAC of P || Q.
+func (f *FF1) prf(p, q []byte, d int) []byte {
+	r := make([]byte, 16)
+	for _, data := range [][]byte{p, q} {
+		for i := 0; i < len(data); i += 16 {
+			for j := 0; j < 16; j++ {
+				r[j] ^= data[i+j]
+			}
+			f.block.Encrypt(r, r)
+		}
+	}
+
+	s := make([]byte, 0, d+15)
+	s = append(s, r...)
+	for j := uint64(1); len(s) < d; j++ {
+		block := make([]byte, 16)
+		copy(block, r)
+		var ctr [8]byte
+		binary.BigEndian.PutUint64(ctr[:], j)
+		for k := 0; k < 8; k++ {
+			block[8+k] ^= ctr[k]
+		}
+		f.block.Encrypt(block, block)
+		s = append(s, block...)
+	}
+	return s[:d]
+}
+
+// Returns the value of a numeral string (most significant numeral first).
+func num(x []uint16, radix *big.Int) *big.Int {
+	result := new(big.Int)
+	for _, d := range x {
+		result.Mul(result, radix)
+		result.Add(result, big.NewInt(int64(d)))
+	}
+	return result
+}
+
+// Returns the m-numeral representation of x (most significant numeral first).
+func str(x *big.Int, radix *big.Int, m int) []uint16 {
+	result := make([]uint16, m)
+	x = new(big.Int).Set(x)
+	d := new(big.Int)
+	for i := m - 1; i >= 0; i-- {
+		x.DivMod(x, radix, d)
+		result[i] = uint16(d.Int64())
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package fpe_test
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xeger/pipeclean/fpe"
+)
+
+const alphabet36 = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+func numerals(s string) []uint16 {
+	x := make([]uint16, len(s))
+	for i, c := range s {
+		x[i] = uint16(strings.IndexRune(alphabet36, c))
+	}
+	return x
+}
+
+// Sample vectors from NIST SP 800-38G.
+func TestFF1(t *testing.T) {
+	cases := []struct {
+		key, tweak string
+		radix      int
+		pt, ct     string
+	}{
+		{"2B7E151628AED2A6ABF7158809CF4F3C", "", 10, "0123456789", "2433477484"},
+		{"2B7E151628AED2A6ABF7158809CF4F3C", "39383736353433323130", 10, "0123456789", "6124200773"},
+		{"2B7E151628AED2A6ABF7158809CF4F3C", "3737373770717273373737", 36, "0123456789abcdefghi", "a9tv40mll9kdu509eum"},
+		{"2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F", "", 10, "0123456789", "2830668132"},
+		{"2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", "", 10, "0123456789", "6657667009"},
+	}
+
+	for _, c := range cases {
+		key, _ := hex.DecodeString(c.key)
+		tweak, _ := hex.DecodeString(c.tweak)
+		ff1, err := fpe.NewFF1(key, c.radix)
+		if err != nil {
+			t.Fatalf("NewFF1: %s", err)
+		}
+
+		ct, err := ff1.Encrypt(numerals(c.pt), tweak)
+		if err != nil {
+			t.Fatalf("Encrypt: %s", err)
+		}
+		if exp := numerals(c.ct); !reflect.DeepEqual(ct, exp) {
+			t.Errorf("Encrypt(%s) = %v, want %v", c.pt, ct, exp)
+		}
+
+		pt, err := ff1.Decrypt(ct, tweak)
+		if err != nil {
+			t.Fatalf("Decrypt: %s", err)
+		}
+		if exp := numerals(c.pt); !reflect.DeepEqual(pt, exp) {
+			t.Errorf("Decrypt(%s) = %v, want %v", c.ct, pt, exp)
+		}
+	}
+}
+
+func TestFF1Domain(t *testing.T) {
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	ff1, _ := fpe.NewFF1(key, 10)
+	if _, err := ff1.Encrypt(numerals("12345"), nil); err != fpe.ErrDomain {
+		t.Errorf("Encrypt of 10^5 domain: got %v, want ErrDomain", err)
+	}
+	if _, err := ff1.Encrypt(numerals("123456"), nil); err != nil {
+		t.Errorf("Encrypt of 10^6 domain: %s", err)
+	}
+}
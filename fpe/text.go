@@ -0,0 +1,196 @@
+package fpe
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Smallest domain that NIST SP 800-38G allows for FF1.
+const minDomain = 1000000
+
+// Text encrypts strings while preserving their format: each ASCII digit is
+// replaced by a digit, each lowercase letter by a lowercase letter and each
+// uppercase letter by an uppercase letter. Other characters are unchanged.
+//
+// The letters and digits of a string are read as a single mixed-radix
+// number, which is encrypted with FF1 (radix 10) and cycle-walking so that
+// the result is again a valid mixed-radix number of the same shape. Numbers
+// from a domain too small for FF1 (fewer than 10^6 values) are instead
+// permuted by a table, which is built on first use and then kept.
+//
+// A Text is not safe for concurrent use.
+type Text struct {
+	ff1   *FF1
+	small map[int64]*permutation
+}
+
+// NewText creates a Text cipher. The key must be 16, 24 or 32 bytes long.
+func NewText(key []byte) (*Text, error) {
+	ff1, err := NewFF1(key, 10)
+	if err != nil {
+		return nil, err
+	}
+	return &Text{ff1: ff1, small: make(map[int64]*permutation)}, nil
+}
+
+// Encrypt encrypts s, preserving its length and format.
+func (t *Text) Encrypt(s string) string {
+	return t.cipher(s, true)
+}
+
+// Decrypt recovers a string encrypted with the same key.
+func (t *Text) Decrypt(s string) string {
+	return t.cipher(s, false)
+}
+
+// EncryptNumber is like Encrypt for the decimal representation of a number,
+// but never gives a multi-digit integer part a leading zero (or a negative
+// number the value zero), which would be lost when the result is stored as
+// a number.
+func (t *Text) EncryptNumber(s string) string {
+	if unstable(s) {
+		return t.Encrypt(s)
+	}
+	for s = t.Encrypt(s); unstable(s); s = t.Encrypt(s) {
+	}
+	return s
+}
+
+// DecryptNumber recovers a number encrypted with EncryptNumber.
+func (t *Text) DecryptNumber(s string) string {
+	if unstable(s) {
+		return t.Decrypt(s)
+	}
+	for s = t.Decrypt(s); unstable(s); s = t.Decrypt(s) {
+	}
+	return s
+}
+
+// Reports whether a number would change when stored as such: its integer
+// part has several digits, the first of which is zero, or it is a negative
+// zero.
+func unstable(s string) bool {
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	if len(s) > 1 && s[0] == '0' && s[1] >= '0' && s[1] <= '9' {
+		return true
+	}
+	return negative && strings.Trim(s, "0.") == ""
+}
+
+// Returns the first character of the class to which c belongs, and the size
+// of the class; the size is 0 for characters that are not encrypted.
+func class(c byte) (byte, int64) {
+	switch {
+	case c >= '0' && c <= '9':
+		return '0', 10
+	case c >= 'a' && c <= 'z':
+		return 'a', 26
+	case c >= 'A' && c <= 'Z':
+		return 'A', 26
+	}
+	return 0, 0
+}
+
+func (t *Text) cipher(s string, encrypt bool) string {
+	b := []byte(s)
+
+	positions := make([]int, 0, len(b))
+	value, domain := new(big.Int), big.NewInt(1)
+	for i, c := range b {
+		if base, size := class(c); size > 0 {
+			positions = append(positions, i)
+			radix := big.NewInt(size)
+			value.Mul(value, radix)
+			value.Add(value, big.NewInt(int64(c-base)))
+			domain.Mul(domain, radix)
+		}
+	}
+	if len(positions) == 0 {
+		return s
+	}
+
+	if domain.Cmp(big.NewInt(minDomain)) < 0 {
+		n := domain.Int64()
+		p := t.small[n]
+		if p == nil {
+			p = newPermutation(t.ff1.block, n)
+			t.small[n] = p
+		}
+		if encrypt {
+			value.SetInt64(int64(p.fwd[value.Int64()]))
+		} else {
+			value.SetInt64(int64(p.inv[value.Int64()]))
+		}
+		return spell(b, positions, value)
+	}
+
+	ten := big.NewInt(10)
+	width := len(new(big.Int).Sub(domain, big.NewInt(1)).String())
+	for {
+		var x []uint16
+		var err error
+		if encrypt {
+			x, err = t.ff1.Encrypt(str(value, ten, width), nil)
+		} else {
+			x, err = t.ff1.Decrypt(str(value, ten, width), nil)
+		}
+		if err != nil {
+			// should never happen; width is always sufficient
+			panic(err)
+		}
+		value = num(x, ten)
+		if value.Cmp(domain) < 0 {
+			break
+		}
+	}
+
+	return spell(b, positions, value)
+}
+
+// Writes the digits of a mixed-radix number into the given positions of b.
+func spell(b []byte, positions []int, value *big.Int) string {
+	d := new(big.Int)
+	for i := len(positions) - 1; i >= 0; i-- {
+		base, size := class(b[positions[i]])
+		value.DivMod(value, big.NewInt(size), d)
+		b[positions[i]] = base + byte(d.Int64())
+	}
+	return string(b)
+}
+
+// A keyed permutation of [0, n) for domains that are too small for FF1: the
+// "prefix cipher" of Black and Rogaway, which ranks every element by its
+// AES encryption.
+type permutation struct {
+	fwd, inv []uint32
+}
+
+func newPermutation(block cipher.Block, n int64) *permutation {
+	tags := make([]uint64, n)
+	in, out := make([]byte, 16), make([]byte, 16)
+	// unlike the first block of FF1's PRF, which begins with 1, 2, 1
+	in[0] = 0xff
+	binary.BigEndian.PutUint32(in[4:], uint32(n))
+	for i := range tags {
+		binary.BigEndian.PutUint32(in[12:], uint32(i))
+		block.Encrypt(out, in)
+		tags[i] = binary.BigEndian.Uint64(out)
+	}
+
+	p := &permutation{fwd: make([]uint32, n), inv: make([]uint32, n)}
+	for i := range p.inv {
+		p.inv[i] = uint32(i)
+	}
+	sort.Slice(p.inv, func(a, b int) bool {
+		x, y := p.inv[a], p.inv[b]
+		return tags[x] < tags[y] || (tags[x] == tags[y] && x < y)
+	})
+	for rank, i := range p.inv {
+		p.fwd[i] = uint32(rank)
+	}
+	return p
+}
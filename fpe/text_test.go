@@ -0,0 +1,91 @@
+package fpe_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/xeger/pipeclean/fpe"
+)
+
+func TestText(t *testing.T) {
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	text, err := fpe.NewText(key)
+	if err != nil {
+		t.Fatalf("NewText: %s", err)
+	}
+
+	for _, s := range []string{"7", "joe@foo.com", "+1 (555) 867-5309", "Jane Q. Public", "ß--", "4111111111111111"} {
+		ct := text.Encrypt(s)
+		if len(ct) != len(s) {
+			t.Errorf("Encrypt(%q) = %q, want same length", s, ct)
+		}
+		for i := 0; i < len(s); i++ {
+			a, b := s[i], ct[i]
+			switch {
+			case a >= '0' && a <= '9':
+				if b < '0' || b > '9' {
+					t.Errorf("Encrypt(%q) = %q, want digits to stay digits", s, ct)
+				}
+			case a >= 'a' && a <= 'z':
+				if b < 'a' || b > 'z' {
+					t.Errorf("Encrypt(%q) = %q, want lowercase to stay lowercase", s, ct)
+				}
+			case a >= 'A' && a <= 'Z':
+				if b < 'A' || b > 'Z' {
+					t.Errorf("Encrypt(%q) = %q, want uppercase to stay uppercase", s, ct)
+				}
+			default:
+				if a != b {
+					t.Errorf("Encrypt(%q) = %q, want punctuation to be preserved", s, ct)
+				}
+			}
+		}
+		if pt := text.Decrypt(ct); pt != s {
+			t.Errorf("Decrypt(%q) = %q, want %q", ct, pt, s)
+		}
+	}
+}
+
+func TestTextSmallDomain(t *testing.T) {
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	text, _ := fpe.NewText(key)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		s := fmt.Sprintf("%02d", i)
+		ct := text.Encrypt(s)
+		if seen[ct] {
+			t.Errorf("Encrypt(%q) = %q, which is not unique", s, ct)
+		}
+		seen[ct] = true
+		if pt := text.Decrypt(ct); pt != s {
+			t.Errorf("Decrypt(%q) = %q, want %q", ct, pt, s)
+		}
+	}
+}
+
+func TestTextNumber(t *testing.T) {
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	text, _ := fpe.NewText(key)
+
+	for i := -1000; i < 1000; i++ {
+		s := strconv.Itoa(i)
+		ct := text.EncryptNumber(s)
+		if len(ct) != len(s) || strconv.Itoa(mustAtoi(t, ct)) != ct {
+			t.Errorf("EncryptNumber(%q) = %q, which is not a number of the same width", s, ct)
+		}
+		if pt := text.DecryptNumber(ct); pt != s {
+			t.Errorf("DecryptNumber(%q) = %q, want %q", ct, pt, s)
+		}
+	}
+}
+
+func mustAtoi(t *testing.T, s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatalf("not a number: %q", s)
+	}
+	return n
+}
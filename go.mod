@@ -5,6 +5,9 @@ go 1.20
 require (
 	github.com/pingcap/tidb/parser v0.0.0-20230402100455-fc0751d0f9bc
 	github.com/spf13/cobra v1.6.1
+	github.com/xeger/gomarkov v0.0.0-20230408162331-d474dcd89b82
+	golang.org/x/exp v0.0.0-20230321023759-10a507213a29
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,12 +18,9 @@ require (
 	github.com/pingcap/log v1.1.0 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	github.com/xeger/gomarkov v0.0.0-20230408162331-d474dcd89b82 // indirect
 	go.uber.org/atomic v1.10.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.24.0 // indirect
-	golang.org/x/exp v0.0.0-20230321023759-10a507213a29 // indirect
 	golang.org/x/text v0.8.0 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
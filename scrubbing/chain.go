@@ -0,0 +1,80 @@
+package scrubbing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xeger/pipeclean/cmd/ui"
+	"github.com/xeger/pipeclean/nlp"
+)
+
+// Transform applies a "truncate(length)", "lowercase" or "uppercase"
+// disposition. Transforms do not scrub by themselves; they adjust the output
+// of other steps of a chain, e.g. to fit a column or to normalize case.
+func transform(s string, disposition Disposition) string {
+	switch disposition.Action() {
+	case "lowercase":
+		return strings.ToLower(s)
+	case "uppercase":
+		return strings.ToUpper(s)
+	}
+	length, err := truncateLength(disposition)
+	if err != nil {
+		// should never happen if Policy has been properly validated
+		ui.ExitBug(err.Error())
+	}
+	if r := []rune(s); len(r) > length {
+		return string(r[:length])
+	}
+	return s
+}
+
+// Parses the length of a "truncate(length)" disposition.
+func truncateLength(d Disposition) (int, error) {
+	length, err := strconv.Atoi(strings.TrimSpace(d.Parameter()))
+	if err != nil || length < 1 {
+		return 0, fmt.Errorf("truncate needs a positive length (got %q)", d.Parameter())
+	}
+	return length, nil
+}
+
+// FirstStep returns the step of a disposition that sees the original value:
+// the disposition itself, or the first step of a chain. Domains are resolved
+// (see Resolve).
+func (p Policy) FirstStep(d Disposition) Disposition {
+	return p.Resolve(p.Resolve(d).Steps()[0])
+}
+
+// Checks the parameters of a transform disposition.
+func validateTransform(d Disposition) error {
+	switch d.Action() {
+	case "truncate":
+		if _, err := truncateLength(d); err != nil {
+			return err
+		}
+	default:
+		if d.Parameter() != "" {
+			return fmt.Errorf("%s takes no parameters", d.Action())
+		}
+	}
+	return nil
+}
+
+// Checks the steps of a chain. Since every step processes the output of the
+// previous one, a chain cannot erase data, and steps may be transforms (or
+// "pass") even where they could not stand alone.
+func (p Policy) validateChain(d Disposition, models map[string]nlp.Model) error {
+	for i, step := range d.Steps() {
+		if step == "" {
+			return fmt.Errorf("empty step %d of chain", i+1)
+		}
+		if step.Action() == "erase" {
+			return fmt.Errorf("erase cannot be chained")
+		}
+		if err := p.validateDisposition(step, models, true); err != nil {
+			return fmt.Errorf("%w in step %d of chain", err, i+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package scrubbing
+
+import (
+	"sync"
+
+	"github.com/xeger/pipeclean/cmd/ui"
+)
+
+// Classifier enforces Policy.Unclassified on behalf of formats that know the
+// names of the fields they scrub. It is safe for concurrent use, so every
+// scrubber of a run can share one. A nil *Classifier checks nothing.
+type Classifier struct {
+	mx sync.Mutex
+
+	policy *Policy
+	seen   map[string]bool
+	err    error
+}
+
+// NewClassifier returns a Classifier for policy, or nil if the policy does
+// not require fields to be classified.
+func NewClassifier(policy *Policy) *Classifier {
+	if policy.Unclassified == "" {
+		return nil
+	}
+	return &Classifier{policy: policy, seen: make(map[string]bool)}
+}
+
+// Check verifies that a field matches some field-name rule; field is the
+// name under which to report it. Each unclassified field is reported at
+// most once.
+func (c *Classifier) Check(field string, names []string) {
+	if c == nil || len(names) == 0 {
+		return
+	}
+	if disposition, _ := c.policy.MatchFieldName(names); disposition != "" {
+		return
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.seen[field] {
+		return
+	}
+	c.seen[field] = true
+
+	switch c.policy.Unclassified {
+	case "abort":
+		if c.err == nil {
+			c.err = &UnclassifiedError{Field: field}
+		}
+	case "report":
+		ui.Warnf("Unclassified field %q", field)
+	}
+}
+
+// Err returns an *UnclassifiedError if the policy says to abort and an
+// unclassified field has been seen; the caller should stop scrubbing.
+func (c *Classifier) Err() error {
+	if c == nil {
+		return nil
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.err
+}
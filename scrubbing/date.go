@@ -0,0 +1,66 @@
+package scrubbing
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/xeger/pipeclean/rand"
+)
+
+// ReDate identifies SQL-style dates and datetimes, as well as ISO-8601
+// timestamps, with optional fractional seconds and time zone.
+var reDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(?:([ T])\d{2}:\d{2}:\d{2}(\.\d{1,9})?(Z|[+-]\d{2}:?\d{2})?)?$`)
+
+// A date parsed from a string, retaining enough information to format a
+// shifted date in exactly the same way.
+type date struct {
+	t      time.Time
+	layout string
+	// Fractional seconds and time zone, which are never changed by shifting
+	// the date by whole days.
+	suffix string
+}
+
+// Parses a date, datetime or timestamp. Returns false for strings that are
+// not dates, and for invalid dates such as MySQL's zero dates.
+func parseDate(s string) (date, bool) {
+	m := reDate.FindStringSubmatchIndex(s)
+	if m == nil {
+		return date{}, false
+	}
+	layout := "2006-01-02"
+	end := len(s)
+	if m[2] >= 0 {
+		layout += s[m[2]:m[3]] + "15:04:05"
+		for _, i := range []int{m[4], m[6]} {
+			if i >= 0 && i < end {
+				end = i
+			}
+		}
+	}
+	t, err := time.Parse(layout, s[:end])
+	if err != nil {
+		return date{}, false
+	}
+	return date{t: t, layout: layout, suffix: s[end:]}, true
+}
+
+// Formats a date that has been moved by some number of days (and seconds),
+// or in the opposite direction if that would overflow a four-digit year.
+func (d date) shift(days, seconds int) string {
+	shifted := d.t.AddDate(0, 0, days).Add(time.Duration(seconds) * time.Second)
+	if shifted.Year() > 9999 || shifted.Year() < 1 {
+		shifted = d.t.AddDate(0, 0, -days).Add(time.Duration(-seconds) * time.Second)
+	}
+	return shifted.Format(d.layout) + d.suffix
+}
+
+// Returns a pseudorandom number of days in [-max, -1] or [1, max].
+func randomDays(seed string, max int) int {
+	rand := rand.NewRand(seed)
+	days := 1 + rand.Intn(max)
+	if rand.Intn(2) == 0 {
+		days = -days
+	}
+	return days
+}
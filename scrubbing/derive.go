@@ -0,0 +1,90 @@
+package scrubbing
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Matches a placeholder of a "derive(template)" disposition, e.g.
+// "{first_name}".
+var reDerivePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// Limits how deeply derived fields may refer to other derived fields, so
+// that fields which refer to each other cannot recurse forever.
+const maxDeriveDepth = 8
+
+// Derive builds a value from a "derive(template)" disposition by replacing
+// each placeholder of the template, e.g. "{first_name}", with the scrubbed
+// value of the named field of the current row. Fields are scrubbed exactly
+// as they are in their own right, so the derived value agrees with them.
+//
+// The sibling field is named relative to each of names, e.g. if names is
+// ["email", "users.email"] then "{first_name}" refers to "first_name" and
+// "users.first_name". NULL fields are empty; a field that the row lacks
+// altogether (e.g. a typo in the template) is empty too, but also causes
+// a *DeriveError (see Scrubber.Err).
+func (sc *Scrubber) derive(names []string, disposition Disposition) string {
+	if sc.deriving >= maxDeriveDepth {
+		return ""
+	}
+	sib := *sc
+	sib.deriving++
+	// the sibling's own statistics are recorded when it is scrubbed
+	sib.Verifier = nil
+	template := disposition.Parameters()[0]
+	return reDerivePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		field := placeholder[1 : len(placeholder)-1]
+		v, ok := sc.row[field]
+		if !ok {
+			sc.fail(&DeriveError{Template: template, Field: field})
+			return ""
+		} else if v == nil {
+			return ""
+		}
+		return sib.ScrubString(*v, siblingNames(names, field))
+	})
+}
+
+// DeriveError indicates that the template of a "derive(template)"
+// disposition refers to a field that a record lacks.
+type DeriveError struct {
+	Template, Field string
+}
+
+func (e *DeriveError) Error() string {
+	return fmt.Sprintf("derive template %q refers to unknown field %s", e.Template, e.Field)
+}
+
+// Returns the names of a sibling field, given the names of a field of the
+// same record; e.g. the siblings of "users.email" are named "users.field".
+func siblingNames(names []string, field string) []string {
+	result := []string{field}
+	seen := map[string]bool{field: true}
+	for _, n := range names {
+		if dot := strings.LastIndex(n, "."); dot >= 0 {
+			if sib := n[:dot+1] + field; !seen[sib] {
+				seen[sib] = true
+				result = append(result, sib)
+			}
+		}
+	}
+	return result
+}
+
+// Checks the template of a "derive(template)" disposition.
+func validateDerive(d Disposition) error {
+	params := d.Parameters()
+	if len(params) != 1 || params[0] == "" {
+		return fmt.Errorf("derive needs a template (quote it if it contains commas)")
+	}
+	for _, m := range reDerivePlaceholder.FindAllStringSubmatch(params[0], -1) {
+		if strings.TrimSpace(m[1]) == "" {
+			return fmt.Errorf("derive template %q has an empty placeholder", params[0])
+		}
+	}
+	if rest := reDerivePlaceholder.ReplaceAllString(params[0], ""); strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("derive template %q has unbalanced braces", params[0])
+	}
+	return nil
+}
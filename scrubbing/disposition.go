@@ -1,19 +1,62 @@
 package scrubbing
 
-import "strings"
+import (
+	"encoding/json"
+	"strings"
+)
 
 // Disposition describes how to scrub a piece of data (in the abstract).
 // It can be any of the following:
 //   - "erase": remove the data entirely from the output
 //   - "mask": scramble characters of the data
 //   - "generate(modelName)": create dummy replacement data using the given model
+//   - "encrypt(keyName)": reversibly encrypt the data, preserving its format
+//   - "hash(algorithm, keyName)": replace the data with its hex HMAC digest
+//   - "tokenize(tokenizerName)": replace the data with a configurable HMAC token
+//   - "shift(days, entityField)": move a date by up to the given number of days
+//   - "noise(pct)": perturb a number by up to the given percentage
+//   - "bucket(width)": round a number to the nearest multiple of width
+//   - "redact(pattern, template)": replace the parts of the data that match a
+//     regular expression with a template
+//   - "scan(models...)": replace personal information found in free text
+//   - "derive(template)": build the data from the scrubbed values of other
+//     fields of the same record, e.g. "derive({first_name}@example.com)"
+//   - "domain(domainName)": scrub the data like every other field in the domain
+//   - "truncate(length)": shorten the data to at most the given number of
+//     characters (useful as a step of a chain)
+//   - "lowercase", "uppercase": change the case of the data (ditto)
+//
+// Several dispositions separated by "|" form a chain, whose steps are
+// applied in order, e.g. "generate(givenName) | truncate(20) | lowercase".
+// In JSON, a chain may also be written as a list of steps.
 type Disposition string
 
 func (d Disposition) String() string {
 	return string(d)
 }
 
+// UnmarshalJSON accepts a single disposition, or a list of steps that form
+// a chain.
+func (d *Disposition) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*d = Disposition(s)
+		return nil
+	}
+	var steps []string
+	if err := json.Unmarshal(b, &steps); err != nil {
+		return err
+	}
+	*d = Disposition(strings.Join(steps, " | "))
+	return nil
+}
+
+// Action returns the action of the disposition, e.g. "generate" for
+// "generate(givenName)", or "chain" if it is a chain of several steps.
 func (d Disposition) Action() string {
+	if d.pipe() >= 0 {
+		return "chain"
+	}
 	paren := strings.Index(string(d), "(")
 	if paren >= 0 {
 		return string(d[:paren])
@@ -22,9 +65,112 @@ func (d Disposition) Action() string {
 }
 
 func (d Disposition) Parameter() string {
+	if d.pipe() >= 0 {
+		return ""
+	}
 	paren := strings.Index(string(d), "(")
 	if paren >= 0 {
 		return string(d[paren+1 : len(d)-1])
 	}
 	return ""
 }
+
+// Steps returns the steps of a chain, or the disposition itself if it is
+// not a chain.
+func (d Disposition) Steps() []Disposition {
+	var steps []Disposition
+	for {
+		i := d.pipe()
+		if i < 0 {
+			return append(steps, Disposition(strings.TrimSpace(string(d))))
+		}
+		steps = append(steps, Disposition(strings.TrimSpace(string(d[:i]))))
+		d = d[i+1:]
+	}
+}
+
+// Returns the index of the first "|" that separates steps of a chain, i.e.
+// outside of parameter lists, or -1 if there is none.
+func (d Disposition) pipe() int {
+	if !strings.Contains(string(d), "|") {
+		return -1
+	}
+	depth := 0
+	quoted := false
+	// the last character outside of quotes other than a space
+	last := byte(0)
+	for i := 0; i < len(d); i++ {
+		c := d[i]
+		switch {
+		case quoted:
+			if c == '\'' {
+				if i+1 < len(d) && d[i+1] == '\'' {
+					i++
+				} else {
+					quoted = false
+				}
+			}
+			continue
+		case c == '\'' && depth > 0 && (last == '(' || last == ','):
+			// quotes only count at the start of a parameter (see Parameters)
+			quoted = true
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '|' && depth == 0:
+			return i
+		}
+		if c != ' ' {
+			last = c
+		}
+	}
+	return -1
+}
+
+// Parameters splits a comma-separated parameter list, e.g.
+// "hash(sha256, tokens)" has the parameters "sha256" and "tokens".
+//
+// A parameter may be enclosed in single quotes so that it can contain commas
+// or surrounding spaces, e.g. "redact('\d{1,3}', '#')"; within quotes, two
+// single quotes stand for one.
+func (d Disposition) Parameters() []string {
+	param := strings.TrimSpace(d.Parameter())
+	if param == "" {
+		return nil
+	}
+	var params []string
+	for {
+		var p string
+		if strings.HasPrefix(param, "'") {
+			var sb strings.Builder
+			i := 1
+			for ; i < len(param); i++ {
+				if param[i] == '\'' {
+					if i+1 < len(param) && param[i+1] == '\'' {
+						i++
+					} else {
+						break
+					}
+				}
+				sb.WriteByte(param[i])
+			}
+			p, param = sb.String(), param[i:]
+			// skip the closing quote and anything else before the next comma
+			if comma := strings.Index(param, ","); comma >= 0 {
+				param = param[comma:]
+			} else {
+				param = ""
+			}
+		} else if comma := strings.Index(param, ","); comma >= 0 {
+			p, param = strings.TrimSpace(param[:comma]), param[comma:]
+		} else {
+			p, param = param, ""
+		}
+		params = append(params, p)
+		if param == "" {
+			return params
+		}
+		param = strings.TrimSpace(param[1:])
+	}
+}
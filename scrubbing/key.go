@@ -0,0 +1,83 @@
+package scrubbing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySource tells pipeclean where to find secret key material for
+// dispositions such as "encrypt(keyName)". Keys are never accepted on the
+// command line, since they would be visible to other users and recorded in
+// shell history.
+//
+// The key itself must be hex-encoded; surrounding whitespace is ignored.
+type KeySource struct {
+	// Env is the name of an environment variable that contains the key.
+	Env string `json:"env"`
+	// File is the path of a file that contains the key.
+	File string `json:"file"`
+}
+
+// Load reads and decodes the key material.
+func (ks KeySource) Load() ([]byte, error) {
+	var encoded string
+	switch {
+	case ks.Env != "" && ks.File != "":
+		return nil, fmt.Errorf("ambiguous source (both env and file)")
+	case ks.Env != "":
+		value, ok := os.LookupEnv(ks.Env)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", ks.Env)
+		}
+		encoded = value
+	case ks.File != "":
+		data, err := os.ReadFile(ks.File)
+		if err != nil {
+			return nil, err
+		}
+		encoded = string(data)
+	default:
+		return nil, fmt.Errorf("unknown source (need env or file)")
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key is not hex-encoded")
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key is empty")
+	}
+	return key, nil
+}
+
+// LoadKeys reads the material of every key in the policy so that it is
+// available to the scrubber. It must be called before scrubbing if the
+// policy refers to any key.
+func (p *Policy) LoadKeys() []error {
+	var errs []error
+	p.keys = make(map[string][]byte, len(p.Keys))
+	for name, source := range p.Keys {
+		key, err := source.Load()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %q: %w", name, err))
+			continue
+		}
+		p.keys[name] = key
+	}
+	return errs
+}
+
+// SetKey provides key material directly, e.g. for testing.
+func (p *Policy) SetKey(name string, key []byte) {
+	if p.keys == nil {
+		p.keys = make(map[string][]byte)
+	}
+	p.keys[name] = key
+}
+
+// Key returns the material of a loaded key, or nil if it is not loaded.
+func (p *Policy) Key(name string) []byte {
+	return p.keys[name]
+}
@@ -0,0 +1,201 @@
+package scrubbing
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MappingConfig tells pipeclean where to remember the replacements it makes,
+// so that later runs (including runs of later pipeclean versions, or runs
+// with retrained models) reuse them.
+type MappingConfig struct {
+	// File is the path of the mapping store; it is created if necessary.
+	File string `json:"file"`
+	// Key names the key (see Policy.Keys) with which original values are
+	// hashed, so that the store cannot be used to confirm guesses of
+	// original values without it. It is required.
+	Key string `json:"key"`
+	// Persist lists the dispositions whose replacements are remembered,
+	// either by action (e.g. "generate") or in full (e.g. "generate(name)").
+	// The default is ["generate"].
+	Persist []string `json:"persist"`
+}
+
+// MappingStore is an append-only file that maps keyed hashes of original
+// values to their replacements. It is safe for concurrent use.
+//
+// Each line of the file holds the hex-encoded hash, a tab, and the
+// replacement as a quoted Go string. Original values are never stored.
+type MappingStore struct {
+	mx   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	m    map[string]string
+}
+
+// OpenMappingStore reads the mappings in a file, creating it if necessary,
+// and prepares to record new mappings in it.
+func OpenMappingStore(path string) (*MappingStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	ms := &MappingStore{file: file, m: make(map[string]string)}
+
+	r := bufio.NewReader(file)
+	var size int64
+	for lineno := 1; ; lineno++ {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			// ignore a final line that was cut short (e.g. by a crash)
+			break
+		} else if err != nil {
+			file.Close()
+			return nil, err
+		}
+		key, quoted, _ := strings.Cut(strings.TrimSuffix(line, "\n"), "\t")
+		value, err := strconv.Unquote(quoted)
+		if err != nil || key == "" {
+			file.Close()
+			return nil, fmt.Errorf("%s:%d: malformed mapping", path, lineno)
+		}
+		ms.m[key] = value
+		size += int64(len(line))
+	}
+
+	// append after the last complete line
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	ms.w = bufio.NewWriter(file)
+	return ms, nil
+}
+
+// Lookup returns the replacement recorded under a key.
+func (ms *MappingStore) Lookup(key string) (string, bool) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	value, ok := ms.m[key]
+	return value, ok
+}
+
+// Record remembers the replacement for a key, unless one is already known;
+// it returns the replacement that is in effect.
+func (ms *MappingStore) Record(key, value string) string {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	if prev, ok := ms.m[key]; ok {
+		return prev
+	}
+	ms.m[key] = value
+	// errors are sticky in bufio.Writer and reported by Close
+	fmt.Fprintf(ms.w, "%s\t%s\n", key, strconv.Quote(value))
+	return value
+}
+
+// Close writes any pending mappings to disk and closes the file.
+func (ms *MappingStore) Close() error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	return errors.Join(ms.w.Flush(), ms.file.Close())
+}
+
+// OpenMappings opens the mapping store of the policy, if it has one, so that
+// scrubbers remember their replacements. Call CloseMappings when done.
+func (p *Policy) OpenMappings() error {
+	if p.Mappings == nil {
+		return nil
+	}
+	if p.Key(p.Mappings.Key) == nil {
+		return fmt.Errorf("key %q of mapping store is not loaded", p.Mappings.Key)
+	}
+	ms, err := OpenMappingStore(p.Mappings.File)
+	if err != nil {
+		return err
+	}
+	p.mappings = ms
+	return nil
+}
+
+// CloseMappings writes new mappings to disk and closes the mapping store.
+func (p *Policy) CloseMappings() error {
+	if p.mappings == nil {
+		return nil
+	}
+	err := p.mappings.Close()
+	p.mappings = nil
+	return err
+}
+
+// Reports whether the replacements of a disposition should be remembered.
+// Dispositions that depend on other fields of the record are never
+// remembered, since their replacements are not a function of the value. A
+// chain is remembered if it, or any of its steps, is listed in Persist.
+func (p Policy) persists(d Disposition) bool {
+	if p.mappings == nil {
+		return false
+	}
+	switch d.Action() {
+	case "erase", "pass":
+		return false
+	}
+	persist := p.Mappings.Persist
+	if len(persist) == 0 {
+		persist = []string{"generate"}
+	}
+	listed := func(d Disposition) bool {
+		for _, x := range persist {
+			if x == d.Action() || x == string(d) {
+				return true
+			}
+		}
+		return false
+	}
+	result := listed(d)
+	for _, step := range d.Steps() {
+		for _, inner := range p.Resolve(step).Steps() {
+			switch inner.Action() {
+			case "derive":
+				return false
+			case "shift":
+				if len(inner.Parameters()) > 1 {
+					return false
+				}
+			}
+			result = result || listed(inner)
+		}
+	}
+	return result
+}
+
+// Mapped returns the remembered replacement of s under a disposition, or
+// else computes it with apply (and remembers it if the policy says so).
+//
+// Replacements are keyed by an HMAC of the salt, disposition and value under
+// the store's key, so that fields of a domain share their replacements.
+func (sc *Scrubber) mapped(s string, disposition Disposition, apply func(Disposition) string) string {
+	if sc.maskAll || !sc.policy.persists(disposition) {
+		return apply(disposition)
+	}
+	h := hmac.New(sha256.New, sc.policy.Key(sc.policy.Mappings.Key))
+	fmt.Fprintf(h, "%s\x00%s\x00%s", sc.salt, disposition, s)
+	key := hex.EncodeToString(h.Sum(nil))
+	if out, ok := sc.policy.mappings.Lookup(key); ok {
+		return out
+	}
+	return sc.policy.mappings.Record(key, apply(disposition))
+}
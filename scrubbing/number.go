@@ -0,0 +1,78 @@
+package scrubbing
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xeger/pipeclean/rand"
+)
+
+// ReNumber identifies decimal numbers, which may be written in scientific
+// notation (as some encoders do for floats).
+var reNumber = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// Returns the number of digits after the decimal point of a number (not
+// counting any exponent).
+func scale(s string) int {
+	if e := strings.IndexAny(s, "eE"); e >= 0 {
+		s = s[:e]
+	}
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		return len(s) - dot - 1
+	}
+	return 0
+}
+
+// Noise multiplies a number by a pseudorandom factor between 1-pct/100 and
+// 1+pct/100, seeded by the number itself. The result has the same scale as
+// the input. Returns false if s is not a number.
+func noise(s string, pct float64, salt string) (string, bool) {
+	if !reNumber.MatchString(s) {
+		return "", false
+	}
+	x, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return "", false
+	}
+	r := rand.NewRand(salt + "\x00" + s)
+	factor := new(big.Rat).SetFloat64(1 + pct/100*(2*r.Float64()-1))
+	return formatScale(x.Mul(x, factor), s), true
+}
+
+// Bucket rounds a number to the nearest multiple of width (halves away from
+// zero). The result has the same scale as the input. Returns false if s is
+// not a number.
+func bucket(s string, width string) (string, bool) {
+	if !reNumber.MatchString(s) {
+		return "", false
+	}
+	x, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return "", false
+	}
+	w, ok := new(big.Rat).SetString(width)
+	if !ok || w.Sign() <= 0 {
+		return "", false
+	}
+	// count the number of widths, rounding to the nearest integer
+	n := new(big.Rat).Quo(x, w)
+	count, _ := new(big.Int).SetString(n.FloatString(0), 10)
+	return formatScale(x.Mul(w, new(big.Rat).SetInt(count)), s), true
+}
+
+// Formats x with the same number of decimal places as the number like.
+func formatScale(x *big.Rat, like string) string {
+	out := x.FloatString(scale(like))
+	if out == "-0" || strings.HasPrefix(out, "-0.") && strings.Trim(out[3:], "0") == "" {
+		out = out[1:]
+	}
+	return out
+}
+
+// Validates the parameter of a "noise(pct)" or "bucket(width)" disposition.
+func validateNumeric(d Disposition) bool {
+	f, err := strconv.ParseFloat(d.Parameter(), 64)
+	return err == nil && f > 0
+}
@@ -3,6 +3,9 @@ package scrubbing
 import (
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/xeger/pipeclean/nlp"
 )
@@ -19,6 +22,57 @@ type Policy struct {
 	// Key: model name
 	// Value: disposition when a value matches the model
 	Heuristic []HeuristicRule `json:"heuristic"`
+	// Tables applies to the data of entire tables, e.g. to omit sessions or
+	// audit logs from output altogether (see TableRule).
+	Tables []TableRule `json:"tables"`
+	// Unclassified determines what happens when a field (e.g. a SQL column)
+	// does not match any field-name rule:
+	//   - "": nothing; values may still be scrubbed by heuristic rules (the default)
+	//   - "report": a warning is printed for each such field
+	//   - "abort": scrubbing stops with an error
+	// Use "pass" rules to classify fields that are known to be safe.
+	Unclassified string `json:"unclassified"`
+	// Keys tells where to find secret key material for dispositions that
+	// need it, such as "encrypt(keyName)".
+	// Key: key name
+	// Value: source of the key material (see LoadKeys)
+	Keys map[string]KeySource `json:"keys"`
+	// Tokens configures the "tokenize(name)" disposition.
+	// Key: tokenizer name
+	// Value: tokenizer configuration
+	Tokens map[string]Tokenizer `json:"tokens"`
+	// Domains groups fields that hold the same kind of value, even across
+	// tables (e.g. users.email and invoices.billing_email), so that they are
+	// scrubbed alike: rules refer to a domain with "domain(name)" and are
+	// then treated as if they had the domain's disposition.
+	// Key: domain name
+	// Value: disposition for every field in the domain
+	Domains map[string]Disposition `json:"domains"`
+	// Mappings, if present, makes replacements persist across runs (see
+	// MappingConfig).
+	Mappings *MappingConfig `json:"mappings"`
+
+	keys     map[string][]byte
+	mappings *MappingStore
+}
+
+// Resolve returns the disposition of the named domain if d is a
+// "domain(name)" disposition, and d itself otherwise.
+func (p Policy) Resolve(d Disposition) Disposition {
+	if d.Action() == "domain" {
+		return p.Domains[d.Parameter()]
+	}
+	return d
+}
+
+// UnclassifiedError indicates that a field did not match any field-name
+// rule, although the policy requires every field to be classified.
+type UnclassifiedError struct {
+	Field string
+}
+
+func (e *UnclassifiedError) Error() string {
+	return fmt.Sprintf("unclassified field %q", e.Field)
 }
 
 // DefaultPolicy returns a Policy with broadly-useful defaults
@@ -36,17 +90,97 @@ func DefaultPolicy() *Policy {
 // MatchFieldName returns a Disposition for the given field name
 // if it matches any of the policy's field-name patterns.
 // Otherwise it returns the empty string.
+// Dispositions that refer to a domain are resolved (see Resolve).
+//
+// Conditional rules (see FieldNameRule.When) are disregarded, since their
+// conditions depend on a record; see MatchRow.
 func (p Policy) MatchFieldName(names []string) (Disposition, int) {
-	if len(names) > 0 {
-		for idx, rule := range p.FieldName {
-			for _, n := range names {
-				if rule.In.MatchString(n) {
-					return rule.Out, idx
+	disposition, idx, _ := p.MatchRow(names, nil)
+	return disposition, idx
+}
+
+// MatchRow is like MatchFieldName, but also considers conditional rules,
+// which apply if the other fields of row satisfy their conditions. It
+// returns a *ConditionError if the condition of a rule that matches the
+// names cannot be evaluated; matching then continues with the next rule.
+func (p Policy) MatchRow(names []string, row Row) (Disposition, int, error) {
+	return p.match(names, row, false)
+}
+
+// Matches field-name rules. If column is true, a rule must match some part
+// of the last component of a name (e.g. "emails.id" does not match "email"),
+// so that rules meant for the values of a table's text columns do not
+// apply to its numbers, such as ids.
+func (p Policy) match(names []string, row Row, column bool) (Disposition, int, error) {
+	var err error
+	for idx, rule := range p.FieldName {
+		if !rule.matches(names, column) {
+			continue
+		}
+		// conditions are evaluated only for the rules of matching fields,
+		// since they may refer to fields that other records lack
+		ok, cerr := rule.applies(row)
+		if cerr != nil && err == nil {
+			err = cerr
+		}
+		if ok {
+			return p.Resolve(rule.Out), idx, err
+		}
+	}
+	return "", -1, err
+}
+
+// Reports whether a rule's pattern matches any of a field's names.
+func (r FieldNameRule) matches(names []string, column bool) bool {
+	for _, n := range names {
+		if column && !matchesColumn(r.In, n) {
+			continue
+		}
+		if r.In.MatchString(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether a pattern matches a name in a way that reaches past its
+// last ".", i.e. not only in the table (or object) part of the name.
+func matchesColumn(re *regexp.Regexp, name string) bool {
+	dot := strings.LastIndex(name, ".")
+	for _, loc := range re.FindAllStringIndex(name, -1) {
+		if loc[1] > dot {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesRows reports whether any of the policy's rules or dispositions depend
+// on other fields of the same record (see Scrubber.WithRow), so that callers can
+// avoid the cost of collecting rows otherwise.
+func (p Policy) UsesRows() bool {
+	uses := func(d Disposition) bool {
+		for _, step := range p.Resolve(d).Steps() {
+			// a step may refer to a domain that is itself a chain
+			for _, inner := range p.Resolve(step).Steps() {
+				if inner.Action() == "derive" || (inner.Action() == "shift" && len(inner.Parameters()) > 1) {
+					return true
 				}
 			}
 		}
+		return false
+	}
+	for _, rule := range p.FieldName {
+		if rule.When != nil || uses(rule.Out) {
+			return true
+		}
+	}
+	for _, rule := range p.Heuristic {
+		if uses(rule.Out) {
+			return true
+		}
 	}
-	return "", -1
+	return false
 }
 
 // Validate checks that the policy is internally consistent.
@@ -54,41 +188,169 @@ func (p Policy) Validate(models map[string]nlp.Model) []error {
 	var errs []error
 
 	for i, rule := range p.FieldName {
-		switch rule.Out.Action() {
-		case "erase", "mask", "pass", "replace":
-			continue
-		case "generate":
-			model := models[rule.Out.Parameter()]
-			if model == nil {
-				errs = append(errs, fmt.Errorf("unrecognized model %q for fieldname[%d]", rule.Out.Parameter(), i))
-			} else if _, ok := model.(nlp.Generator); !ok {
-				errs = append(errs, fmt.Errorf("model %q for fieldname[%d] is not a generator", rule.Out.Parameter(), i))
-			}
-		default:
-			errs = append(errs, fmt.Errorf("unknown policy action %q for fieldname[%d]", rule.Out.Action(), i))
+		if err := p.validateDisposition(rule.Out, models, true); err != nil {
+			errs = append(errs, fmt.Errorf("%w for fieldname[%d]", err, i))
 		}
 	}
 
+	for i, rule := range p.Tables {
+		if err := validateTable(rule); err != nil {
+			errs = append(errs, fmt.Errorf("%w for tables[%d]", err, i))
+		}
+	}
+
+	switch p.Unclassified {
+	case "", "abort", "report":
+	default:
+		errs = append(errs, fmt.Errorf("unknown unclassified-field handling %q", p.Unclassified))
+	}
+
 	for i, rule := range p.Heuristic {
 
 		modelIn := models[rule.In]
 		if modelIn == nil {
 			errs = append(errs, fmt.Errorf("unrecognized model %q for heuristic[%d]", rule.In, i))
 		}
-		switch rule.Out.Action() {
-		case "erase", "mask", "replace":
-			continue
-		case "generate":
-			modelOut := models[rule.Out.Parameter()]
-			if modelOut == nil {
-				errs = append(errs, fmt.Errorf("unrecognized output model %q for heuristic[%d]", rule.Out.Parameter(), i))
-			} else if _, ok := modelOut.(nlp.Generator); !ok {
-				errs = append(errs, fmt.Errorf("model %q for heuristic[%d] is not a generator", rule.Out.Parameter(), i))
-			}
-		default:
-			errs = append(errs, fmt.Errorf("unknown policy action %q for heuristic[%d]", rule.Out.Action(), i))
+		if err := p.validateDisposition(rule.Out, models, false); err != nil {
+			errs = append(errs, fmt.Errorf("%w for heuristic[%d]", err, i))
+		}
+	}
+
+	if p.Mappings != nil {
+		if p.Mappings.File == "" {
+			errs = append(errs, fmt.Errorf("mappings need a file"))
+		}
+		if p.Mappings.Key == "" {
+			errs = append(errs, fmt.Errorf("mappings need a key"))
+		} else if _, ok := p.Keys[p.Mappings.Key]; !ok && p.keys[p.Mappings.Key] == nil {
+			errs = append(errs, fmt.Errorf("unrecognized key %q for mappings", p.Mappings.Key))
+		}
+	}
+
+	names := make([]string, 0, len(p.Domains))
+	for name := range p.Domains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d := p.Domains[name]
+		if refersToDomain(d) {
+			errs = append(errs, fmt.Errorf("domain %q refers to another domain", name))
+		} else if err := p.validateDisposition(d, models, true); err != nil {
+			errs = append(errs, fmt.Errorf("%w for domain %q", err, name))
 		}
 	}
 
 	return errs
 }
+
+// Checks a single disposition; "pass" (and transforms, which do not scrub by
+// themselves) are only meaningful for field-name rules.
+func (p Policy) validateDisposition(d Disposition, models map[string]nlp.Model, pass bool) error {
+	switch d.Action() {
+	case "erase", "mask", "replace":
+		return nil
+	case "pass":
+		if pass {
+			return nil
+		}
+	case "truncate", "lowercase", "uppercase":
+		if pass {
+			return validateTransform(d)
+		}
+	case "chain":
+		return p.validateChain(d, models)
+	case "encrypt", "hash", "tokenize":
+		return p.validateSecret(d)
+	case "shift":
+		return validateShift(d)
+	case "noise", "bucket":
+		if !validateNumeric(d) {
+			return fmt.Errorf("%s needs a positive number", d.Action())
+		}
+		return nil
+	case "redact":
+		return validateRedact(d)
+	case "scan":
+		return validateScan(d, models)
+	case "derive":
+		return validateDerive(d)
+	case "generate":
+		model := models[d.Parameter()]
+		if model == nil {
+			return fmt.Errorf("unrecognized model %q", d.Parameter())
+		} else if _, ok := model.(nlp.Generator); !ok {
+			return fmt.Errorf("model %q is not a generator", d.Parameter())
+		}
+		return nil
+	case "domain":
+		if _, ok := p.Domains[d.Parameter()]; !ok {
+			return fmt.Errorf("unrecognized domain %q", d.Parameter())
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown policy action %q", d.Action())
+}
+
+// Reports whether any step of a disposition refers to a domain.
+func refersToDomain(d Disposition) bool {
+	for _, step := range d.Steps() {
+		if step.Action() == "domain" {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks that a disposition that relies on a secret key refers to a known
+// key (and, for "tokenize", to a valid tokenizer).
+func (p Policy) validateSecret(d Disposition) error {
+	var name string
+	switch d.Action() {
+	case "encrypt":
+		name = d.Parameter()
+	case "hash":
+		params := d.Parameters()
+		if len(params) != 2 {
+			return fmt.Errorf("hash needs an algorithm and a key name")
+		}
+		if err := (Tokenizer{Algorithm: params[0]}).Validate(); err != nil {
+			return err
+		}
+		name = params[1]
+	case "tokenize":
+		tok, ok := p.Tokens[d.Parameter()]
+		if !ok {
+			return fmt.Errorf("unrecognized tokenizer %q", d.Parameter())
+		}
+		if err := tok.Validate(); err != nil {
+			return fmt.Errorf("tokenizer %q: %w", d.Parameter(), err)
+		}
+		name = tok.Key
+	}
+
+	if _, ok := p.Keys[name]; !ok && p.keys[name] == nil {
+		return fmt.Errorf("unrecognized key %q", name)
+	}
+	if key := p.keys[name]; key != nil && d.Action() == "encrypt" {
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return fmt.Errorf("key %q must be 16, 24 or 32 bytes long to encrypt", name)
+		}
+	}
+	return nil
+}
+
+// Checks the parameters of a "shift(days)" or "shift(days, entityField)"
+// disposition.
+func validateShift(d Disposition) error {
+	params := d.Parameters()
+	if len(params) < 1 || len(params) > 2 {
+		return fmt.Errorf("shift needs a number of days and optionally an entity field")
+	}
+	if days, err := strconv.Atoi(params[0]); err != nil || days < 1 {
+		return fmt.Errorf("shift needs a positive number of days (got %q)", params[0])
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package scrubbing
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/xeger/pipeclean/cmd/ui"
+)
+
+// Compiled patterns of "redact" dispositions, by pattern.
+var redactPatterns sync.Map
+
+// Returns the compiled pattern of a "redact(pattern, template)" disposition.
+func redactPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := redactPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	redactPatterns.Store(pattern, re)
+	return re, nil
+}
+
+// Redact replaces each part of s that matches the pattern of a
+// "redact(pattern, template)" disposition with the template, in which $1 or
+// ${name} stand for capture groups. If the template is omitted, the matching
+// parts are masked instead. Parts that do not match are preserved.
+func (sc *Scrubber) redact(s string, disposition Disposition) string {
+	params := disposition.Parameters()
+	re, err := redactPattern(params[0])
+	if err != nil {
+		// should never happen if Policy has been properly validated
+		ui.ExitBug(fmt.Sprintf("invalid pattern for redact action: %s", err))
+	}
+	if len(params) < 2 {
+		return re.ReplaceAllStringFunc(s, sc.maskWord)
+	}
+	return re.ReplaceAllString(s, params[1])
+}
+
+// Checks the parameters of a "redact(pattern)" or "redact(pattern, template)"
+// disposition.
+func validateRedact(d Disposition) error {
+	params := d.Parameters()
+	if len(params) < 1 || len(params) > 2 || params[0] == "" {
+		return fmt.Errorf("redact needs a pattern and optionally a template")
+	}
+	if _, err := redactPattern(params[0]); err != nil {
+		return fmt.Errorf("redact pattern %q: %w", params[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package scrubbing
+
+// Row holds the (unscrubbed) values of the fields of a record, such as a SQL
+// row, so that dispositions can depend on other fields of the same record.
+// Each value is stored under every name of its field. NULL fields are stored
+// as nil, so that they can be told apart from fields the record lacks.
+type Row map[string]*string
+
+// Set records the value of a field, given the names of the field.
+func (r Row) Set(names []string, value string) {
+	for _, n := range names {
+		r[n] = &value
+	}
+}
+
+// SetNull records that a field is NULL, given the names of the field.
+func (r Row) SetNull(names []string) {
+	for _, n := range names {
+		r[n] = nil
+	}
+}
+
+// Get returns the value of the named field, or false if it is NULL or the
+// record has no such field.
+func (r Row) Get(name string) (string, bool) {
+	if v := r[name]; v != nil {
+		return *v, true
+	}
+	return "", false
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+
+	"github.com/xeger/pipeclean/filtering"
 )
 
 // FieldNameRule describes a scrubbing policy based on the name of a field.
@@ -11,27 +13,67 @@ import (
 type FieldNameRule struct {
 	// In is a field-name matching pattern to test whether this rule applied.
 	In *regexp.Regexp
+	// When, if not nil, is a condition on the other fields of the same record
+	// (e.g. "kind = 'company'") that must be satisfied for this rule to apply.
+	When *filtering.Condition
 	// Out describes what to do when a value satisfies this rule.
 	Out Disposition
 }
 
 type fieldNameRuleJSON struct {
-	In  string
-	Out string
+	In   string
+	When *filtering.Condition `json:",omitempty"`
+	Out  Disposition
 }
 
 func (r *FieldNameRule) MarshalJSON() ([]byte, error) {
 	obj := fieldNameRuleJSON{
-		In:  r.In.String(),
-		Out: string(r.Out),
+		In:   r.In.String(),
+		When: r.When,
+		Out:  r.Out,
 	}
 	return json.Marshal(obj)
 }
 
 func (r FieldNameRule) String() string {
+	if r.When != nil {
+		return fmt.Sprintf("%s (when %s) ―➤ %s", r.In.String(), r.When, r.Out.String())
+	}
 	return fmt.Sprintf("%s ―➤ %s", r.In.String(), r.Out.String())
 }
 
+// Reports whether the rule's condition is satisfied by a record. Without a
+// record, only unconditional rules apply. The condition cannot be evaluated
+// if it refers to a field that the record lacks (e.g. because of a typo).
+func (r FieldNameRule) applies(row Row) (bool, error) {
+	if r.When == nil {
+		return true, nil
+	}
+	if row == nil {
+		return false, nil
+	}
+	ok, err := r.When.Eval("", filtering.Strings(row))
+	if err != nil {
+		return false, &ConditionError{Rule: r, Err: err}
+	}
+	return ok, nil
+}
+
+// ConditionError indicates that the condition of a field-name rule could not
+// be evaluated for a record.
+type ConditionError struct {
+	Rule FieldNameRule
+	Err  error
+}
+
+func (e *ConditionError) Error() string {
+	return fmt.Sprintf("field-name rule %s: %s", e.Rule, e.Err)
+}
+
+func (e *ConditionError) Unwrap() error {
+	return e.Err
+}
+
 func (r *FieldNameRule) UnmarshalJSON(b []byte) error {
 	var obj fieldNameRuleJSON
 	err := json.Unmarshal(b, &obj)
@@ -45,7 +87,8 @@ func (r *FieldNameRule) UnmarshalJSON(b []byte) error {
 		r.In = in
 	}
 
-	r.Out = Disposition(obj.Out)
+	r.When = obj.When
+	r.Out = obj.Out
 
 	return nil
 }
@@ -0,0 +1,258 @@
+package scrubbing
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/xeger/pipeclean/nlp"
+	"github.com/xeger/pipeclean/rand"
+)
+
+// Detector finds one kind of personal information in free text.
+type detector struct {
+	pattern *regexp.Regexp
+	// Valid, if not nil, rejects candidate matches (e.g. by checksum).
+	valid func(s string) bool
+	// Replace computes the replacement of a match.
+	replace func(sc *Scrubber, s string) string
+}
+
+// Built-in detectors for the "scan(models...)" disposition, by name.
+var detectors = map[string]detector{
+	"email": {
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+		replace: (*Scrubber).mask,
+	},
+	"phone": {
+		pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\) ?|\d{3}[ .-]?)\d{3}[ .-]?\d{4}`),
+		replace: (*Scrubber).maskWord,
+	},
+	"card": {
+		pattern: regexp.MustCompile(`\d(?:[ -]?\d){12,18}`),
+		valid:   luhn,
+		replace: (*Scrubber).maskWord,
+	},
+	"iban": {
+		pattern: regexp.MustCompile(`[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}`),
+		valid:   iban,
+		replace: func(sc *Scrubber, s string) string {
+			return s[:2] + sc.maskWord(s[2:])
+		},
+	},
+	"ip": {
+		pattern: regexp.MustCompile(`\d{1,3}(?:\.\d{1,3}){3}|[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`),
+		valid: func(s string) bool {
+			return strings.ContainsAny(s, "0123456789abcdefABCDEF") && net.ParseIP(s) != nil
+		},
+		replace: (*Scrubber).maskIP,
+	},
+}
+
+// Detector names in the order they are applied, so that results do not
+// depend on map iteration order.
+var detectorNames = []string{"email", "iban", "card", "phone", "ip"}
+
+// Luhn reports whether the digits of s have a valid Luhn check digit, as
+// credit card numbers do.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return sum%10 == 0
+}
+
+// Iban reports whether s (which may contain spaces) has valid IBAN check
+// digits.
+func iban(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	var digits strings.Builder
+	for _, c := range s[4:] + s[:4] {
+		if c >= 'A' && c <= 'Z' {
+			fmt.Fprintf(&digits, "%d", c-'A'+10)
+		} else {
+			digits.WriteRune(c)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && n.Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// MaskIP replaces an IP address with a pseudorandom address of the same
+// family.
+func (sc *Scrubber) maskIP(s string) string {
+	ip := net.ParseIP(s)
+	r := rand.NewRand(sc.salt + "\x00" + s)
+	if ip4 := ip.To4(); ip4 != nil && !strings.Contains(s, ":") {
+		out := make(net.IP, net.IPv4len)
+		r.Read(out)
+		return out.String()
+	}
+	out := make(net.IP, net.IPv6len)
+	r.Read(out)
+	return out.String()
+}
+
+// A part of a string that should be replaced.
+type span struct {
+	start, end  int
+	replacement string
+}
+
+// Scan finds personal information in free text and replaces it, preserving
+// the rest of the text, as specified by a "scan(models...)" disposition.
+// Each parameter names a model, or else one of the built-in detectors; with
+// no parameters, all built-in detectors are used.
+//
+// Detectors are matched against the whole text, whereas models are asked to
+// recognize each word. A model must be as confident about a word as a
+// heuristic rule that uses the model requires (or else fully confident).
+// Words recognized by a generator model are replaced with generated words;
+// all other matches are masked.
+func (sc *Scrubber) scan(s string, disposition Disposition) string {
+	names := disposition.Parameters()
+	if len(names) == 0 {
+		names = detectorNames
+	}
+
+	var spans []span
+	var models []string
+	for _, name := range names {
+		if sc.models[name] != nil {
+			models = append(models, name)
+			continue
+		}
+		det := detectors[name]
+		for _, loc := range det.pattern.FindAllStringIndex(s, -1) {
+			match := s[loc[0]:loc[1]]
+			if !isBoundary(s, loc[0], loc[1]) || (det.valid != nil && !det.valid(match)) {
+				continue
+			}
+			spans = append(spans, span{loc[0], loc[1], det.replace(sc, match)})
+		}
+	}
+
+	if len(models) > 0 {
+		for _, loc := range words(s) {
+			word := s[loc[0]:loc[1]]
+			for _, name := range models {
+				model := sc.models[name]
+				if model.Recognize(word) < 1.0-sc.policy.threshold(name) {
+					continue
+				}
+				replacement := sc.maskWord(word)
+				if generator, ok := model.(nlp.Generator); ok && !sc.maskAll {
+					replacement = nlp.ToSameCase(generator.Generate(word), word)
+				}
+				spans = append(spans, span{loc[0], loc[1], replacement})
+				break
+			}
+		}
+	}
+
+	if len(spans) == 0 {
+		return s
+	}
+
+	// Replace spans from left to right, preferring the longest of any spans
+	// that overlap.
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+	var sb strings.Builder
+	pos := 0
+	for _, sp := range spans {
+		if sp.start < pos {
+			continue
+		}
+		sb.WriteString(s[pos:sp.start])
+		sb.WriteString(sp.replacement)
+		pos = sp.end
+	}
+	sb.WriteString(s[pos:])
+	return sb.String()
+}
+
+// Reports whether s[start:end] is not part of a longer word or number.
+func isBoundary(s string, start, end int) bool {
+	if r, _ := utf8.DecodeLastRuneInString(s[:start]); start > 0 && isWordRune(r) {
+		return false
+	}
+	if r, _ := utf8.DecodeRuneInString(s[end:]); end < len(s) && isWordRune(r) {
+		return false
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Words returns the locations of the words of s: runs of non-space
+// characters, without leading or trailing punctuation.
+func words(s string) [][]int {
+	var locs [][]int
+	for _, loc := range reWord.FindAllStringIndex(s, -1) {
+		start, end := loc[0], loc[1]
+		for start < end {
+			r, n := utf8.DecodeRuneInString(s[start:end])
+			if isWordRune(r) {
+				break
+			}
+			start += n
+		}
+		for end > start {
+			r, n := utf8.DecodeLastRuneInString(s[start:end])
+			if isWordRune(r) {
+				break
+			}
+			end -= n
+		}
+		if start < end {
+			locs = append(locs, []int{start, end})
+		}
+	}
+	return locs
+}
+
+var reWord = regexp.MustCompile(`\S+`)
+
+// Threshold returns the P of the first heuristic rule that uses the named
+// model, or 0 if there is none.
+func (p Policy) threshold(model string) float64 {
+	for _, rule := range p.Heuristic {
+		if rule.In == model {
+			return rule.P
+		}
+	}
+	return 0
+}
+
+// Checks the parameters of a "scan(models...)" disposition.
+func validateScan(d Disposition, models map[string]nlp.Model) error {
+	for _, name := range d.Parameters() {
+		if models[name] == nil && detectors[name].pattern == nil {
+			return fmt.Errorf("unrecognized model or detector %q for scan", name)
+		}
+	}
+	return nil
+}
@@ -1,9 +1,11 @@
 package scrubbing
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"net/mail"
 	"net/url"
 	"regexp"
@@ -11,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/xeger/pipeclean/cmd/ui"
+	"github.com/xeger/pipeclean/fpe"
 	"github.com/xeger/pipeclean/nlp"
 	"github.com/xeger/pipeclean/rand"
 	"gopkg.in/yaml.v3"
@@ -27,17 +30,32 @@ func isJsonData(s string) bool {
 	return false
 }
 
+// Reports whether a JSON decoder has consumed all of its input.
+func isEOF(dec *json.Decoder) bool {
+	_, err := dec.Token()
+	return err == io.EOF
+}
+
 func isYamlData(s string) bool {
 	return strings.Index(s, "---\n") == 0
 }
 
 type Scrubber struct {
-	maskAll  bool
-	models   map[string]nlp.Model
-	policy   *Policy
-	salt     string
-	shallow  bool
+	maskAll bool
+	models  map[string]nlp.Model
+	policy  *Policy
+	salt    string
+	shallow bool
+	row     Row
+	// Depth of nested "derive" dispositions (see derive).
+	deriving int
+	// Ciphers for "encrypt" dispositions, by key name.
+	ciphers map[string]*fpe.Text
+	// The first error of the scrubber and its copies (see Err).
+	err      *error
 	Verifier *Verifier
+	// Classifier, if set, is consulted by formats for every field they scrub.
+	Classifier *Classifier
 }
 
 func NewScrubber(salt string, maskAll bool, policy *Policy, models map[string]nlp.Model) *Scrubber {
@@ -46,6 +64,46 @@ func NewScrubber(salt string, maskAll bool, policy *Policy, models map[string]nl
 		maskAll: maskAll,
 		policy:  policy,
 		salt:    salt,
+		ciphers: make(map[string]*fpe.Text),
+		err:     new(error),
+	}
+}
+
+// Policy returns the policy that the scrubber applies.
+func (sc *Scrubber) Policy() *Policy {
+	return sc.policy
+}
+
+// WithRow returns a copy of the scrubber that consults row for dispositions
+// and rules that depend on other fields of the same record, e.g.
+// "shift(days, field)".
+func (sc *Scrubber) WithRow(row Row) *Scrubber {
+	c := *sc
+	c.row = row
+	return &c
+}
+
+// MatchFieldName is like Policy.MatchFieldName, but also considers
+// conditional rules if the scrubber has a row (see WithRow).
+func (sc *Scrubber) MatchFieldName(names []string) (Disposition, int) {
+	disposition, ruleIndex, err := sc.policy.MatchRow(names, sc.row)
+	sc.fail(err)
+	return disposition, ruleIndex
+}
+
+// Err returns the first error that kept the scrubber, or any copy of it
+// (see WithRow), from applying its policy as written; e.g. a
+// *ConditionError if a rule's condition refers to a field that a record
+// lacks, or a *DeriveError if a derive template does. Scrubbing carries on regardless, so the caller should check Err
+// and stop scrubbing.
+func (sc *Scrubber) Err() error {
+	return *sc.err
+}
+
+// Records an error for Err, unless one has been recorded already.
+func (sc *Scrubber) fail(err error) {
+	if err != nil && *sc.err == nil {
+		*sc.err = err
 	}
 }
 
@@ -59,7 +117,7 @@ func NewScrubber(salt string, maskAll bool, policy *Policy, models map[string]nl
 // miss statistics under the assumption that the caller will always try
 // to call ScrubString() if this returns false.
 func (sc *Scrubber) EraseString(s string, names []string) bool {
-	if disposition, ruleIndex := sc.policy.MatchFieldName(names); disposition != "" {
+	if disposition, ruleIndex := sc.MatchFieldName(names); disposition != "" {
 		if sc.Verifier != nil {
 			sc.Verifier.recordFieldName(s, "", names, ruleIndex, disposition)
 		}
@@ -81,19 +139,30 @@ func (sc *Scrubber) EraseString(s string, names []string) bool {
 }
 
 // ScrubData recursively scrubs maps and arrays in-place.
-// It records no statistics with the Verifier.
+//
+// Nested values are named by their key, and also by their path relative to
+// each of names; for example, if names is ["settings", "users.settings"],
+// then a value at {"profile":{"emails":["..."]}} is named "profile.emails[]",
+// "settings.profile.emails[]" and "users.settings.profile.emails[]".
 func (sc *Scrubber) ScrubData(data any, names []string) any {
 	switch v := data.(type) {
 	case string:
 		return sc.ScrubString(v, names)
+	case json.Number:
+		return sameNumber(v, sc.ScrubNumber(v.String(), names))
+	case float64:
+		return sameNumber(v, sc.ScrubNumber(strconv.FormatFloat(v, 'f', -1, 64), names))
+	case int:
+		return sameNumber(v, sc.ScrubNumber(strconv.Itoa(v), names))
 	case []any:
+		elemNames := ElementNames(names)
 		for i, e := range v {
-			v[i] = sc.ScrubData(e, []string{strconv.Itoa(i)})
+			v[i] = sc.ScrubData(e, elemNames)
 		}
 		return v
 	case map[string]any:
 		for k, e := range v {
-			v[k] = sc.ScrubData(e, []string{k})
+			v[k] = sc.ScrubData(e, KeyNames(names, k))
 		}
 		return v
 	default:
@@ -101,42 +170,64 @@ func (sc *Scrubber) ScrubData(data any, names []string) any {
 	}
 }
 
+// Converts the output of ScrubNumber to the same type as the original number
+// if possible (e.g. a "replace" disposition may produce a non-number).
+func sameNumber(orig any, s string) any {
+	if s == "" {
+		return nil
+	}
+	if !reNumber.MatchString(s) {
+		return s
+	}
+	switch orig.(type) {
+	case json.Number:
+		return json.Number(s)
+	case int:
+		if i, err := strconv.Atoi(s); err == nil {
+			return i
+		}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// ElementNames returns the names of the elements of an array, given the
+// names of the array itself (see ScrubData).
+func ElementNames(names []string) []string {
+	elemNames := make([]string, 0, len(names))
+	for _, n := range names {
+		elemNames = append(elemNames, n+"[]")
+	}
+	if len(elemNames) == 0 {
+		elemNames = append(elemNames, "[]")
+	}
+	return elemNames
+}
+
+// KeyNames returns the names of the value at a given key of a map, given
+// the names of the map itself (see ScrubData).
+func KeyNames(names []string, key string) []string {
+	keyNames := make([]string, 0, len(names)+1)
+	keyNames = append(keyNames, key)
+	for _, n := range names {
+		keyNames = append(keyNames, n+"."+key)
+	}
+	return keyNames
+}
+
 // ScrubString applies rules to sanitize a string, preserving values that do
 // not match any rule.
 // It records statistics if a Verifier is provided.
 func (sc *Scrubber) ScrubString(s string, names []string) string {
 	handle := func(disposition Disposition) string {
-		switch disposition.Action() {
-		case "erase":
-			return ""
-		case "generate":
-			if sc.maskAll {
-				return sc.mask(s)
-			}
-			if model := sc.models[disposition.Parameter()]; model != nil {
-				if generator, ok := model.(nlp.Generator); ok {
-					return nlp.ToSameCase(generator.Generate(s), s)
-				}
-			} else {
-				// should never happen if Policy has been properly validated
-				panic("unknown model name for generate action: " + disposition.Action())
-			}
-		case "mask":
-			return sc.mask(s)
-		case "pass":
-			return s
-		case "replace":
-			// TODO
-			return sc.replace(s, disposition.Parameter())
-		}
-		// should never happen if Policy has been properly validated
-		ui.ExitBug("unknown policy action: " + disposition.Action())
-		return ""
+		return sc.apply(s, names, disposition)
 	}
 
 	// First match against field-name rules
-	if disposition, ruleIndex := sc.policy.MatchFieldName(names); disposition != "" {
-		out := handle(disposition)
+	if disposition, ruleIndex := sc.MatchFieldName(names); disposition != "" {
+		out := sc.mapped(s, disposition, handle)
 		if sc.Verifier != nil {
 			sc.Verifier.recordFieldName(s, out, names, ruleIndex, disposition)
 		}
@@ -147,9 +238,10 @@ func (sc *Scrubber) ScrubString(s string, names []string) string {
 	for ruleIndex, rule := range sc.policy.Heuristic {
 		model := sc.models[rule.In]
 		if model.Recognize(s) >= (1.0 - rule.P) {
-			out := handle(rule.Out)
+			disposition := sc.policy.Resolve(rule.Out)
+			out := sc.mapped(s, disposition, handle)
 			if sc.Verifier != nil {
-				sc.Verifier.recordHeuristic(s, out, names, ruleIndex, rule.Out)
+				sc.Verifier.recordHeuristic(s, out, names, ruleIndex, disposition)
 			}
 			return out
 		}
@@ -160,8 +252,10 @@ func (sc *Scrubber) ScrubString(s string, names []string) string {
 		var data any
 
 		if isJsonData(s) {
-			if err := json.Unmarshal([]byte(s), &data); err == nil {
-				scrubbed, err := json.Marshal(sc.ScrubData(data, nil))
+			dec := json.NewDecoder(strings.NewReader(s))
+			dec.UseNumber()
+			if err := dec.Decode(&data); err == nil && isEOF(dec) {
+				scrubbed, err := json.Marshal(sc.ScrubData(data, names))
 				if err != nil {
 					ui.Fatal(err)
 				}
@@ -171,7 +265,7 @@ func (sc *Scrubber) ScrubString(s string, names []string) string {
 			if err := yaml.Unmarshal([]byte(s), &data); err == nil {
 				switch v := data.(type) {
 				case []any, map[string]any:
-					scrubbed, err := yaml.Marshal(sc.ScrubData(v, nil))
+					scrubbed, err := yaml.Marshal(sc.ScrubData(v, names))
 					if err != nil {
 						ui.Fatal(err)
 					}
@@ -192,6 +286,164 @@ func (sc *Scrubber) ScrubString(s string, names []string) string {
 	return s
 }
 
+// Applies a disposition to a string; the steps of a chain are applied in
+// order, each to the output of the previous one.
+func (sc *Scrubber) apply(s string, names []string, disposition Disposition) string {
+	switch disposition.Action() {
+	case "encrypt":
+		return sc.encrypt(s, disposition.Parameter(), false)
+	case "erase":
+		return ""
+	case "hash", "tokenize":
+		return sc.tokenize(s, disposition)
+	case "shift":
+		if d, ok := parseDate(s); ok {
+			return sc.shift(s, d, disposition)
+		}
+		return sc.mask(s)
+	case "noise", "bucket":
+		if out, ok := sc.perturb(s, disposition); ok {
+			return out
+		}
+		return sc.mask(s)
+	case "redact":
+		return sc.redact(s, disposition)
+	case "scan":
+		return sc.scan(s, disposition)
+	case "derive":
+		return sc.derive(names, disposition)
+	case "generate":
+		if sc.maskAll {
+			return sc.mask(s)
+		}
+		if model := sc.models[disposition.Parameter()]; model != nil {
+			if generator, ok := model.(nlp.Generator); ok {
+				return nlp.ToSameCase(generator.Generate(s), s)
+			}
+		} else {
+			// should never happen if Policy has been properly validated
+			panic("unknown model name for generate action: " + disposition.Action())
+		}
+	case "mask":
+		return sc.mask(s)
+	case "pass":
+		return s
+	case "replace":
+		// TODO
+		return sc.replace(s, disposition.Parameter())
+	case "truncate", "lowercase", "uppercase":
+		return transform(s, disposition)
+	case "chain":
+		for _, step := range disposition.Steps() {
+			s = sc.apply(s, names, sc.policy.Resolve(step))
+		}
+		return s
+	}
+	// should never happen if Policy has been properly validated
+	ui.ExitBug("unknown policy action: " + disposition.Action())
+	return ""
+}
+
+// ScrubNumber applies field-name rules to the decimal representation of a
+// number, returning another decimal representation of the same shape. It
+// returns the empty string if the number should be erased.
+//
+// Heuristic rules are not consulted because numbers carry too little
+// information for models to recognize them. Generation is not meaningful for
+// numbers, so "generate" dispositions mask the number instead. A rule must
+// match the last component of a name to apply, so that the ids of an
+// "emails" table are not masked by a rule for "email".
+func (sc *Scrubber) ScrubNumber(s string, names []string) string {
+	disposition, ruleIndex, err := sc.policy.match(names, sc.row, true)
+	sc.fail(err)
+	if disposition == "" {
+		return s
+	}
+
+	out := sc.applyNumber(s, names, disposition)
+	if sc.Verifier != nil {
+		sc.Verifier.recordFieldName(s, out, names, ruleIndex, disposition)
+	}
+	return out
+}
+
+// Applies a disposition to the decimal representation of a number (see
+// ScrubNumber).
+func (sc *Scrubber) applyNumber(s string, names []string, disposition Disposition) string {
+	switch disposition.Action() {
+	case "encrypt":
+		return sc.encrypt(s, disposition.Parameter(), true)
+	case "hash", "tokenize":
+		tok := sc.tokenizer(disposition)
+		return tok.NumberToken(sc.policy.Key(tok.Key), s)
+	case "erase":
+		return ""
+	case "generate", "mask", "scan", "shift":
+		return sc.maskWord(s)
+	case "noise", "bucket":
+		if out, ok := sc.perturb(s, disposition); ok {
+			return out
+		}
+		return sc.maskWord(s)
+	case "pass":
+		return s
+	case "redact":
+		return sc.redact(s, disposition)
+	case "derive":
+		return sc.derive(names, disposition)
+	case "replace":
+		return disposition.Parameter()
+	case "truncate", "lowercase", "uppercase":
+		return transform(s, disposition)
+	case "chain":
+		for _, step := range disposition.Steps() {
+			s = sc.applyNumber(s, names, sc.policy.Resolve(step))
+		}
+		return s
+	}
+	// should never happen if Policy has been properly validated
+	ui.ExitBug("unknown policy action: " + disposition.Action())
+	return ""
+}
+
+// ScrubBytes applies field-name rules to opaque binary data, returning
+// scrambled data of the same length. It returns nil if the data should be
+// erased.
+//
+// Heuristic rules are not consulted because binary data cannot be recognized
+// by models. Any disposition other than "erase" or "pass" scrambles the data,
+// preserving leading zero bits so that the result fits wherever the input did
+// (e.g. a BIT(3) column).
+func (sc *Scrubber) ScrubBytes(b []byte, names []string) []byte {
+	disposition, ruleIndex := sc.MatchFieldName(names)
+	if disposition == "" {
+		return b
+	}
+
+	var out []byte
+	switch disposition.Action() {
+	case "erase":
+		out = nil
+	case "pass":
+		out = b
+	default:
+		out = make([]byte, len(b))
+		rand.NewRand(string(b)).Read(out)
+		if len(b) > 0 {
+			m := b[0]
+			m |= m >> 1
+			m |= m >> 2
+			m |= m >> 4
+			out[0] &= m
+		}
+	}
+
+	if sc.Verifier != nil {
+		sc.Verifier.recordFieldName(hex.EncodeToString(b), hex.EncodeToString(out), names, ruleIndex, disposition)
+	}
+	return out
+}
+
 // Mask scrambles the numeric or alphabetic characters in a string, preserving
 // other characters (punctuation, etc) and preserving the length of the string.
 //
@@ -262,6 +514,69 @@ func (sc *Scrubber) maskWord(s string) string {
 	return string(sb)
 }
 
+// Shift moves a date by a pseudorandom number of days, as specified by a
+// "shift(days)" or "shift(days, entityField)" disposition. The offset is
+// seeded by the value of the entity field in the current row if there is
+// one, so that all dates of one entity move together; otherwise it is
+// seeded by the date itself.
+func (sc *Scrubber) shift(s string, d date, disposition Disposition) string {
+	params := disposition.Parameters()
+	max, _ := strconv.Atoi(params[0])
+	seed := "\x00" + s
+	if len(params) > 1 {
+		if entity, ok := sc.row.Get(params[1]); ok {
+			seed = entity
+		}
+	}
+	return d.shift(randomDays(sc.salt+"\x00"+seed, max), 0)
+}
+
+// Perturb applies a "noise(pct)" or "bucket(width)" disposition to the
+// decimal representation of a number. Returns false if s is not a number.
+func (sc *Scrubber) perturb(s string, disposition Disposition) (string, bool) {
+	if disposition.Action() == "bucket" {
+		return bucket(s, disposition.Parameter())
+	}
+	pct, _ := strconv.ParseFloat(disposition.Parameter(), 64)
+	return noise(s, pct, sc.salt)
+}
+
+// Encrypt applies format-preserving encryption to a string (or to the
+// decimal representation of a number) using the named key, so that it can
+// later be recovered with the same key.
+func (sc *Scrubber) encrypt(s, keyName string, number bool) string {
+	text := sc.ciphers[keyName]
+	if text == nil {
+		var err error
+		text, err = fpe.NewText(sc.policy.Key(keyName))
+		if err != nil {
+			// should never happen if Policy has been properly validated
+			ui.ExitBug(fmt.Sprintf("invalid key %q for encrypt action: %s", keyName, err))
+		}
+		sc.ciphers[keyName] = text
+	}
+	if number {
+		return text.EncryptNumber(s)
+	}
+	return text.Encrypt(s)
+}
+
+// Tokenize replaces a string with a keyed HMAC token, as specified by a
+// "hash(algorithm, keyName)" or "tokenize(tokenizerName)" disposition.
+func (sc *Scrubber) tokenize(s string, disposition Disposition) string {
+	tok := sc.tokenizer(disposition)
+	return tok.Token(sc.policy.Key(tok.Key), s)
+}
+
+// Returns the Tokenizer that a "hash" or "tokenize" disposition refers to.
+func (sc *Scrubber) tokenizer(disposition Disposition) Tokenizer {
+	if disposition.Action() == "hash" {
+		params := disposition.Parameters()
+		return Tokenizer{Algorithm: params[0], Key: params[1]}
+	}
+	return sc.policy.Tokens[disposition.Parameter()]
+}
+
 // Replace returns its second parameter, ignoring the first.
 func (sc *Scrubber) replace(s string, replacement string) string {
 	return replacement
@@ -1,13 +1,21 @@
 package scrubbing_test
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"reflect"
 	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/xeger/pipeclean/filtering"
+	"github.com/xeger/pipeclean/fpe"
 	"github.com/xeger/pipeclean/nlp"
 	"github.com/xeger/pipeclean/scrubbing"
 )
@@ -123,6 +131,608 @@ func TestDispositionMask(t *testing.T) {
 	}
 }
 
+func TestDispositionMaskDate(t *testing.T) {
+	field := "email"
+
+	// dates are masked like any other string (only "shift" keeps them
+	// valid): digits are scrambled, and zeros and punctuation are preserved
+	reDigits := regexp.MustCompile(`[1-9]`)
+	for _, s := range []string{"1985-04-12", "2000-02-29 23:59:59"} {
+		got := scrub(s, field)
+		if got == s || reDigits.ReplaceAllString(got, "1") != reDigits.ReplaceAllString(s, "1") {
+			t.Errorf(`scrub(%q) = %q, want its digits masked`, s, got)
+		}
+	}
+
+	// zero dates are not valid dates; digits are masked (i.e. left alone)
+	if got := scrub("0000-00-00", field); got != "0000-00-00" {
+		t.Errorf(`scrub(%q) = %q, want unchanged`, "0000-00-00", got)
+	}
+}
+
+func TestScrubNumber(t *testing.T) {
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile("salary"), Out: "mask"},
+			{In: regexp.MustCompile("age"), Out: "erase"},
+			{In: regexp.MustCompile("score"), Out: "replace(0)"},
+		},
+	}
+	sc := scrubbing.NewScrubber(salt, false, policy, nil)
+
+	if got := sc.ScrubNumber("52000.50", []string{"salary"}); got == "52000.50" || !regexp.MustCompile(`^[1-9]\d{4}\.\d0$`).MatchString(got) {
+		t.Errorf(`ScrubNumber(salary) = %q, want a masked decimal`, got)
+	}
+	if got := sc.ScrubNumber("42", []string{"age"}); got != "" {
+		t.Errorf(`ScrubNumber(age) = %q, want ""`, got)
+	}
+	if got := sc.ScrubNumber("42", []string{"score"}); got != "0" {
+		t.Errorf(`ScrubNumber(score) = %q, want "0"`, got)
+	}
+	if got := sc.ScrubNumber("42", []string{"id"}); got != "42" {
+		t.Errorf(`ScrubNumber(id) = %q, want "42"`, got)
+	}
+	if got := sc.ScrubBytes([]byte{0x05, 0xff}, []string{"salary"}); len(got) != 2 || got[0] > 0x07 {
+		t.Errorf(`ScrubBytes(salary) = %x, want two bytes with leading zero bits`, got)
+	}
+	if got := sc.ScrubBytes([]byte{0x05}, []string{"age"}); got != nil {
+		t.Errorf(`ScrubBytes(age) = %x, want nil`, got)
+	}
+}
+
+func TestDispositionEncrypt(t *testing.T) {
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile("email"), Out: "encrypt(support)"},
+		},
+	}
+	policy.SetKey("support", key)
+
+	in := "Joe.Bloggs@example.com"
+	got := scrubWithPolicy(in, "email", policy, nil)
+	if got == in || !regexp.MustCompile(`^[A-Z][a-z]{2}\.[A-Z][a-z]{5}@[a-z]{7}\.[a-z]{3}$`).MatchString(got) {
+		t.Errorf(`scrub(%q) = %q, want same format`, in, got)
+	}
+	text, _ := fpe.NewText(key)
+	if dec := text.Decrypt(got); dec != in {
+		t.Errorf(`Decrypt(%q) = %q, want %q`, got, dec, in)
+	}
+
+	policy.SetKey("short", []byte("short"))
+	policy.FieldName[0].Out = "encrypt(short)"
+	if errs := policy.Validate(nil); errs == nil {
+		t.Errorf("Validate accepted a key of invalid length")
+	}
+	policy.FieldName[0].Out = "encrypt(unknown)"
+	if errs := policy.Validate(nil); errs == nil {
+		t.Errorf("Validate accepted an unknown key")
+	}
+}
+
+func TestDispositionHash(t *testing.T) {
+	// RFC 4231 test case 1
+	key, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile("customer_id"), Out: "hash(sha256, tokens)"},
+			{In: regexp.MustCompile("phone"), Out: "tokenize(phone)"},
+			{In: regexp.MustCompile("ssn"), Out: "tokenize(ssn)"},
+		},
+		Tokens: map[string]scrubbing.Tokenizer{
+			"phone": {Key: "tokens", Alphabet: "0123456789", Length: 10},
+			"ssn":   {Key: "tokens", Algorithm: "sha512", Encoding: "base32", Length: 16},
+		},
+	}
+	policy.SetKey("tokens", key)
+
+	exp := "b0344c61d8db38535ca8afceaf0bf12b881dc200c9833da726e9376c2e32cff7"
+	if got := scrubWithPolicy("Hi There", "customer_id", policy, nil); got != exp {
+		t.Errorf(`scrub(%q) = %q, want %q`, "Hi There", got, exp)
+	}
+
+	phone := scrubWithPolicy("555-867-5309", "phone", policy, nil)
+	if !regexp.MustCompile(`^\d{10}$`).MatchString(phone) {
+		t.Errorf(`scrub(%q) = %q, want 10 digits`, "555-867-5309", phone)
+	}
+	if again := scrubWithPolicy("555-867-5309", "phone", policy, nil); again != phone {
+		t.Errorf(`scrub(%q) is not deterministic: %q vs %q`, "555-867-5309", phone, again)
+	}
+	if ssn := scrubWithPolicy("078-05-1120", "ssn", policy, nil); !regexp.MustCompile(`^[A-Z2-7]{16}$`).MatchString(ssn) {
+		t.Errorf(`scrub(%q) = %q, want 16 base32 characters`, "078-05-1120", ssn)
+	}
+
+	// numbers get numeric tokens of the same size, whatever the tokenizer
+	sc := scrubbing.NewScrubber(salt, false, policy, nil)
+	for _, field := range []string{"customer_id", "ssn"} {
+		for _, in := range []string{"4217", "-31.05", "7"} {
+			got := sc.ScrubNumber(in, []string{field})
+			if len(got) != len(in) || got == in || !regexp.MustCompile(`^-?([1-9]\d+|\d)(\.\d+)?$`).MatchString(got) {
+				t.Errorf(`ScrubNumber(%q, %s) = %q, want a number of the same size`, in, field, got)
+			}
+			if again := sc.ScrubNumber(in, []string{field}); again != got {
+				t.Errorf(`ScrubNumber(%q, %s) is not deterministic: %q vs %q`, in, field, got, again)
+			}
+		}
+	}
+
+	policy.Tokens["phone"] = scrubbing.Tokenizer{Key: "tokens", Alphabet: "0123456789", Length: 100}
+	if errs := policy.Validate(nil); errs == nil {
+		t.Errorf("Validate accepted a token longer than the digest")
+	}
+	policy.FieldName[0].Out = "hash(md4, tokens)"
+	if errs := policy.Validate(nil); errs == nil {
+		t.Errorf("Validate accepted an unknown algorithm")
+	}
+}
+
+func TestDispositionShift(t *testing.T) {
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile("created_at"), Out: "shift(10, user_id)"},
+		},
+	}
+	sc := scrubbing.NewScrubber(salt, false, policy, nil)
+	names := []string{"created_at"}
+
+	in := "2021-03-04T10:00:00.123+05:30"
+	got := sc.ScrubString(in, names)
+	if got == in || !regexp.MustCompile(`^2021-0[23]-\d{2}T10:00:00\.123\+05:30$`).MatchString(got) {
+		t.Errorf(`ScrubString(%q) = %q, want a nearby date of the same format`, in, got)
+	}
+
+	row := scrubbing.Row{}
+	row.Set([]string{"user_id", "events.user_id"}, "42")
+	a := sc.WithRow(row).ScrubString("2021-03-04", names)
+	b := sc.WithRow(row).ScrubString("2021-06-30", names)
+	ta, _ := time.Parse("2006-01-02", a)
+	tb, _ := time.Parse("2006-01-02", b)
+	if tb.Sub(ta) != 118*24*time.Hour {
+		t.Errorf(`dates of one entity shifted by different offsets: %q, %q`, a, b)
+	}
+
+	if got := sc.ScrubString("not a date", names); got == "not a date" {
+		t.Errorf(`ScrubString(%q) was not masked`, "not a date")
+	}
+
+	policy.FieldName[0].Out = "shift(0)"
+	if errs := policy.Validate(nil); errs == nil {
+		t.Errorf("Validate accepted shift(0)")
+	}
+}
+
+func TestConditionalRules(t *testing.T) {
+	var policy scrubbing.Policy
+	err := json.Unmarshal([]byte(`{"fieldname": [
+		{"in": "^users\\.name$", "when": "is_test_account <> 0", "out": "pass"},
+		{"in": "^users\\.name$", "out": "replace(Jane Doe)"}
+	]}`), &policy)
+	if err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+	if !policy.UsesRows() {
+		t.Errorf("UsesRows() = false for a policy with conditional rules")
+	}
+	sc := scrubbing.NewScrubber(salt, false, &policy, nil)
+	names := []string{"name", "users.name"}
+
+	for isTest, exp := range map[string]string{"1": "Joe Smith", "0": "Jane Doe", "": "Jane Doe"} {
+		row := scrubbing.Row{}
+		row.Set(names, "Joe Smith")
+		if isTest != "" {
+			row.Set([]string{"is_test_account", "users.is_test_account"}, isTest)
+		} else {
+			row.SetNull([]string{"is_test_account", "users.is_test_account"})
+		}
+		if got := sc.WithRow(row).ScrubString("Joe Smith", names); got != exp {
+			t.Errorf("with is_test_account=%q: ScrubString = %q, want %q", isTest, got, exp)
+		}
+	}
+	// without a row, conditions cannot be satisfied
+	if got := sc.ScrubString("Joe Smith", names); got != "Jane Doe" {
+		t.Errorf("without a row: ScrubString = %q, want %q", got, "Jane Doe")
+	}
+	if err := sc.Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+
+	// a condition that refers to a field the row lacks is an error
+	row := scrubbing.Row{}
+	row.Set(names, "Joe Smith")
+	if got := sc.WithRow(row).ScrubString("Joe Smith", names); got != "Jane Doe" {
+		t.Errorf("with unknown is_test_account: ScrubString = %q, want %q", got, "Jane Doe")
+	}
+	var ce *scrubbing.ConditionError
+	var unknown *filtering.UnknownColumnError
+	if err := sc.Err(); !errors.As(err, &ce) || !errors.As(err, &unknown) || unknown.Column != "is_test_account" {
+		t.Errorf("Err() = %v, want a ConditionError for is_test_account", err)
+	}
+
+	err = json.Unmarshal([]byte(`{"fieldname": [{"in": "name", "when": "kind = (SELECT 1)", "out": "pass"}]}`), &policy)
+	if err == nil {
+		t.Errorf("Unmarshal accepted a condition with a subquery")
+	}
+}
+
+func TestDispositionDerive(t *testing.T) {
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^users\.(first|last)_name$`), Out: "mask"},
+			{In: regexp.MustCompile(`^users\.email$`), Out: "derive('{first_name}.{last_name}@example.com')"},
+			{In: regexp.MustCompile(`^users\.full_name$`), Out: "derive({first_name} {middle_name} {last_name})"},
+			{In: regexp.MustCompile(`^users\.loop$`), Out: "derive(x{loop})"},
+		},
+	}
+	if errs := policy.Validate(nil); errs != nil {
+		t.Fatalf("Validate: %v", errs)
+	}
+	if !policy.UsesRows() {
+		t.Errorf("UsesRows() = false for a policy with derived fields")
+	}
+	sc := scrubbing.NewScrubber(salt, false, policy, nil)
+
+	row := scrubbing.Row{}
+	row.Set([]string{"first_name", "users.first_name"}, "Joe")
+	row.Set([]string{"last_name", "users.last_name"}, "Smith")
+	row.SetNull([]string{"middle_name", "users.middle_name"})
+	row.Set([]string{"email", "users.email"}, "joe@smith.com")
+	row.Set([]string{"loop", "users.loop"}, "x")
+	rsc := sc.WithRow(row)
+
+	first := rsc.ScrubString("Joe", []string{"first_name", "users.first_name"})
+	last := rsc.ScrubString("Smith", []string{"last_name", "users.last_name"})
+	if first == "Joe" || last == "Smith" {
+		t.Fatalf("names were not scrubbed: %q %q", first, last)
+	}
+	if got, exp := rsc.ScrubString("joe@smith.com", []string{"email", "users.email"}), first+"."+last+"@example.com"; got != exp {
+		t.Errorf("derived email = %q, want %q", got, exp)
+	}
+	if got, exp := rsc.ScrubString("Joe Smith", []string{"full_name", "users.full_name"}), first+"  "+last; got != exp {
+		t.Errorf("derived full name = %q, want %q", got, exp)
+	}
+	if got := rsc.ScrubString("x", []string{"loop", "users.loop"}); got != "xxxxxxxx" {
+		t.Errorf("self-referential derive = %q, want recursion to stop", got)
+	}
+	if err := rsc.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+
+	// a template that refers to an unknown field is an error, like a typo
+	// in a condition
+	delete(row, "middle_name")
+	rsc.ScrubString("Joe Smith", []string{"full_name", "users.full_name"})
+	var de *scrubbing.DeriveError
+	if err := rsc.Err(); !errors.As(err, &de) || de.Field != "middle_name" {
+		t.Errorf("Err() = %v, want a DeriveError for middle_name", err)
+	}
+
+	for _, bad := range []scrubbing.Disposition{"derive()", "derive({first_name)", "derive({})", "derive({a}, {b})"} {
+		policy.FieldName[1].Out = bad
+		if errs := policy.Validate(nil); errs == nil {
+			t.Errorf("Validate accepted %s", bad)
+		}
+	}
+}
+
+func TestDispositionNoise(t *testing.T) {
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile("salary"), Out: "noise(10)"},
+		},
+	}
+	sc := scrubbing.NewScrubber(salt, false, policy, nil)
+
+	for _, in := range []string{"52000.50", "1985", "-0.125"} {
+		got := sc.ScrubNumber(in, []string{"salary"})
+		x, _ := strconv.ParseFloat(in, 64)
+		y, err := strconv.ParseFloat(got, 64)
+		if err != nil || y < x-math.Abs(x)/10 || y > x+math.Abs(x)/10 {
+			t.Errorf(`ScrubNumber(%q) = %q, want within 10%%`, in, got)
+		}
+		if strings.Count(got, ".") != strings.Count(in, ".") || (strings.Contains(in, ".") && len(got)-strings.Index(got, ".") != len(in)-strings.Index(in, ".")) {
+			t.Errorf(`ScrubNumber(%q) = %q, want same scale`, in, got)
+		}
+		if again := sc.ScrubNumber(in, []string{"salary"}); again != got {
+			t.Errorf(`ScrubNumber(%q) is not deterministic: %q vs %q`, in, got, again)
+		}
+	}
+}
+
+func TestDispositionBucket(t *testing.T) {
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile("salary"), Out: "bucket(10000)"},
+			{In: regexp.MustCompile("age"), Out: "bucket(5)"},
+		},
+	}
+	sc := scrubbing.NewScrubber(salt, false, policy, nil)
+
+	cases := map[string]string{"37": "35", "38": "40", "-3": "-5", "-2": "0", "0": "0"}
+	for in, exp := range cases {
+		if got := sc.ScrubNumber(in, []string{"age"}); got != exp {
+			t.Errorf(`ScrubNumber(%q) = %q, want %q`, in, got, exp)
+		}
+	}
+	if got := sc.ScrubNumber("52000.50", []string{"salary"}); got != "50000.00" {
+		t.Errorf(`ScrubNumber(%q) = %q, want %q`, "52000.50", got, "50000.00")
+	}
+
+	in := `{"age":37,"salary":52000.50,"name":"Joe"}`
+	exp := `{"age":35,"name":"Joe","salary":50000.00}`
+	if got := sc.ScrubString(in, []string{"profile"}); got != exp {
+		t.Errorf(`ScrubString(%q) = %q, want %q`, in, got, exp)
+	}
+	in = "---\nage: 37\n"
+	exp = "age: 35\n"
+	if got := sc.ScrubString(in, []string{"profile"}); got != exp {
+		t.Errorf(`ScrubString(%q) = %q, want %q`, in, got, exp)
+	}
+
+	policy.FieldName[0].Out = "bucket(-1)"
+	if errs := policy.Validate(nil); errs == nil {
+		t.Errorf("Validate accepted bucket(-1)")
+	}
+}
+
+func TestDispositionRedact(t *testing.T) {
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile("card"), Out: `redact('^\d{4}([ -]?)\d{4}[ -]?\d{4}[ -]?(\d{4})$', '****$1****$1****$1$2')`},
+			{In: regexp.MustCompile("email"), Out: `redact('^[^@]+')`},
+			{In: regexp.MustCompile("url"), Out: `redact('([?&]token=)[^&#]*', '${1}REDACTED')`},
+			{In: regexp.MustCompile("quote"), Out: `redact('it''s', 'it is')`},
+		},
+	}
+	if errs := policy.Validate(nil); errs != nil {
+		t.Fatalf("Validate: %v", errs)
+	}
+	sc := scrubbing.NewScrubber(salt, false, policy, nil)
+
+	cases := []struct{ in, field, exp string }{
+		{"4111 1111 1111 1234", "card", "**** **** **** 1234"},
+		{"4111111111111234", "card", "************1234"},
+		{"not a card", "card", "not a card"},
+		{"https://example.com/?a=1&token=s3cr3t&b=2", "url", "https://example.com/?a=1&token=REDACTED&b=2"},
+		{"it's here", "quote", "it is here"},
+	}
+	for _, c := range cases {
+		if got := sc.ScrubString(c.in, []string{c.field}); got != c.exp {
+			t.Errorf(`ScrubString(%q) = %q, want %q`, c.in, got, c.exp)
+		}
+	}
+
+	got := sc.ScrubString("joe.smith@example.com", []string{"email"})
+	if !strings.HasSuffix(got, "@example.com") || got == "joe.smith@example.com" || len(got) != len("joe.smith@example.com") {
+		t.Errorf(`ScrubString(%q) = %q, want masked local part`, "joe.smith@example.com", got)
+	}
+
+	for _, bad := range []scrubbing.Disposition{"redact", "redact('(')", "redact(a, b, c)"} {
+		policy.FieldName[0].Out = bad
+		if errs := policy.Validate(nil); errs == nil {
+			t.Errorf("Validate accepted %s", bad)
+		}
+	}
+}
+
+func TestDispositionChain(t *testing.T) {
+	var policy scrubbing.Policy
+	err := json.Unmarshal([]byte(`{
+		"fieldname": [
+			{"in": "name", "out": ["replace(Jonathan Livingston)", "truncate(8)", "uppercase"]},
+			{"in": "card", "out": "redact('\\d(?:\\d|-)+(\\d{4})', 'x$1') | domain(short)"},
+			{"in": "amount", "out": ["bucket(100)", "truncate(2)"]},
+			{"in": "code", "out": ["mask", "truncate( 3)"]}
+		],
+		"domains": {"short": ["lowercase", "truncate(6)"]}
+	}`), &policy)
+	if err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+	if errs := policy.Validate(nil); errs != nil {
+		t.Fatalf("Validate: %v", errs)
+	}
+	for d, exp := range map[scrubbing.Disposition]int{
+		policy.FieldName[1].Out:            2,
+		"replace(it's) | mask":             2,
+		"redact('a|b', 'it''s|') | mask":   2,
+		"redact('(|)') | mask | lowercase": 3,
+	} {
+		if steps := d.Steps(); len(steps) != exp {
+			t.Errorf("%s: Steps() = %q, want %d steps", d, steps, exp)
+		}
+	}
+	sc := scrubbing.NewScrubber(salt, false, &policy, nil)
+
+	cases := []struct{ in, field, exp string }{
+		{"Joe", "name", "JONATHAN"},
+		{"Card 4111-1111-1111-1234", "card", "card x"},
+	}
+	for _, c := range cases {
+		if got := sc.ScrubString(c.in, []string{c.field}); got != c.exp {
+			t.Errorf(`ScrubString(%q) = %q, want %q`, c.in, got, c.exp)
+		}
+	}
+	if got := sc.ScrubNumber("1234", []string{"amount"}); got != "12" {
+		t.Errorf(`ScrubNumber(%q) = %q, want %q`, "1234", got, "12")
+	}
+	if got := sc.ScrubString("abcdef", []string{"code"}); len(got) != 3 || got == "abc" {
+		t.Errorf(`ScrubString(%q) = %q, want 3 masked characters`, "abcdef", got)
+	}
+
+	for _, bad := range []scrubbing.Disposition{"mask | erase", "mask | truncate(0)", "mask | | mask", "mask | lowercase(x)", "mask | bogus"} {
+		policy.FieldName[0].Out = bad
+		if errs := policy.Validate(nil); errs == nil {
+			t.Errorf("Validate accepted %s", bad)
+		}
+	}
+	policy.FieldName[0].Out = "mask"
+	policy.Heuristic = []scrubbing.HeuristicRule{{In: "x", Out: "lowercase"}}
+	if errs := policy.Validate(map[string]nlp.Model{"x": nlp.NewMatchModel(nil)}); len(errs) != 1 {
+		t.Errorf("Validate: got %v, want an error for a lone transform in a heuristic rule", errs)
+	}
+}
+
+func TestDispositionScan(t *testing.T) {
+	models := map[string]nlp.Model{
+		"fruit": nlp.NewMatchModel([]*regexp.Regexp{regexp.MustCompile(`(?i)^(apple|orange)$`)}),
+	}
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile("notes"), Out: "scan"},
+			{In: regexp.MustCompile("diet"), Out: "scan(fruit, email)"},
+		},
+	}
+	if errs := policy.Validate(models); errs != nil {
+		t.Fatalf("Validate: %v", errs)
+	}
+	sc := scrubbing.NewScrubber(salt, false, policy, models)
+
+	in := "Call Joe at (805) 555-1212 or joe@foo.com; card 4111 1111 1111 1111, IBAN DE89 3704 0044 0532 0130 00, from 192.168.1.20. Order 1234567890123 is fine."
+	got := sc.ScrubString(in, []string{"notes"})
+	for _, secret := range []string{"555-1212", "joe@foo.com", "4111 1111 1111 1111", "3704 0044 0532 0130 00", "192.168.1.20"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("ScrubString(%q) = %q, which still contains %q", in, got, secret)
+		}
+	}
+	for _, prose := range []string{"Call Joe at (", "@iws.com; card ", ", IBAN DE", ", from ", ". Order 1234567890123 is fine."} {
+		if !strings.Contains(got, prose) {
+			t.Errorf("ScrubString(%q) = %q, which lacks %q", in, got, prose)
+		}
+	}
+	if again := sc.ScrubString(in, []string{"notes"}); again != got {
+		t.Errorf("ScrubString(%q) is not deterministic: %q vs %q", in, got, again)
+	}
+
+	in = "An apple, a pear and an Orange."
+	got = sc.ScrubString(in, []string{"diet"})
+	if !strings.HasPrefix(got, "An ") || !strings.Contains(got, ", a pear and an ") || strings.Contains(got, "apple") || strings.Contains(got, "Orange") {
+		t.Errorf("ScrubString(%q) = %q, want only fruit replaced", in, got)
+	}
+
+	policy.FieldName[0].Out = "scan(vegetable)"
+	if errs := policy.Validate(models); errs == nil {
+		t.Errorf("Validate accepted %s", policy.FieldName[0].Out)
+	}
+}
+
+func TestDispositionDomain(t *testing.T) {
+	models := map[string]nlp.Model{
+		"email": nlp.NewMatchModel([]*regexp.Regexp{regexp.MustCompile(`^[^@ ]+@[^@ ]+$`)}),
+	}
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile("billing_email"), Out: "domain(contact)"},
+		},
+		Heuristic: []scrubbing.HeuristicRule{
+			{In: "email", Out: "domain(contact)"},
+		},
+		Domains: map[string]scrubbing.Disposition{
+			"contact": "hash(sha256, secret)",
+		},
+	}
+	policy.SetKey("secret", []byte("key"))
+	if errs := policy.Validate(models); errs != nil {
+		t.Fatalf("Validate: %v", errs)
+	}
+	sc := scrubbing.NewScrubber(salt, false, policy, models)
+
+	byName := sc.ScrubString("joe@foo.com", []string{"billing_email"})
+	byValue := sc.ScrubString("joe@foo.com", []string{"actor"})
+	if byName == "joe@foo.com" || byName != byValue {
+		t.Errorf("fields of one domain were scrubbed differently: %q vs %q", byName, byValue)
+	}
+
+	for _, bad := range []map[string]scrubbing.Disposition{
+		{"other": "mask"},
+		{"contact": "domain(contact)"},
+		{"contact": "generate(email)"},
+	} {
+		policy.Domains = bad
+		if errs := policy.Validate(models); errs == nil {
+			t.Errorf("Validate accepted domains %v", bad)
+		}
+	}
+}
+
+// A generator whose output depends on its training, like a Markov model.
+type trainedGenerator struct{ training string }
+
+func (g *trainedGenerator) Recognize(string) float64 { return 0 }
+func (g *trainedGenerator) Train(s string)           { g.training = s }
+func (g *trainedGenerator) Generate(seed string) string {
+	return g.training + "-" + strconv.Itoa(len(seed))
+}
+
+func TestMappings(t *testing.T) {
+	file := t.TempDir() + "/mappings"
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile("name"), Out: "generate(name)"},
+			{In: regexp.MustCompile("city"), Out: "mask"},
+		},
+		Mappings: &scrubbing.MappingConfig{File: file, Key: "mappings"},
+	}
+	policy.SetKey("mappings", []byte("secret"))
+	gen := &trainedGenerator{training: "v1"}
+	models := map[string]nlp.Model{"name": gen}
+
+	run := func(values ...string) []string {
+		if errs := policy.Validate(models); errs != nil {
+			t.Fatalf("Validate: %v", errs)
+		}
+		if err := policy.OpenMappings(); err != nil {
+			t.Fatalf("OpenMappings: %s", err)
+		}
+		sc := scrubbing.NewScrubber(salt, false, policy, models)
+		out := make([]string, len(values))
+		for i, v := range values {
+			out[i] = sc.ScrubString(v, []string{"name"})
+		}
+		if err := policy.CloseMappings(); err != nil {
+			t.Fatalf("CloseMappings: %s", err)
+		}
+		return out
+	}
+
+	if got := run("alice", "bob"); !reflect.DeepEqual(got, []string{"v1-5", "v1-3"}) {
+		t.Fatalf("first run = %v", got)
+	}
+	gen.Train("v2")
+	if got := run("alice", "carol"); !reflect.DeepEqual(got, []string{"v1-5", "v2-5"}) {
+		t.Errorf("second run = %v, want remembered replacement for alice", got)
+	}
+
+	data, _ := os.ReadFile(file)
+	if strings.Contains(string(data), "alice") || strings.Count(string(data), "\n") != 3 {
+		t.Errorf("unexpected mapping store contents: %q", data)
+	}
+
+	// a line cut short by a crash is discarded
+	os.WriteFile(file, append(data, "deadbeef\t\"v9"...), 0600)
+	if got := run("carol"); !reflect.DeepEqual(got, []string{"v2-5"}) {
+		t.Errorf("third run = %v", got)
+	}
+	if after, _ := os.ReadFile(file); string(after) != string(data) {
+		t.Errorf("mapping store was not repaired: %q", after)
+	}
+
+	// mappings recorded under another key are not reused
+	policy.SetKey("mappings", []byte("other"))
+	if got := run("alice"); !reflect.DeepEqual(got, []string{"v2-5"}) {
+		t.Errorf("run with another key = %v, want fresh replacement", got)
+	}
+
+	os.WriteFile(file, []byte("garbage\n"), 0600)
+	if err := policy.OpenMappings(); err == nil {
+		t.Errorf("OpenMappings accepted a malformed store")
+	}
+
+	policy.Mappings.Key = ""
+	if errs := policy.Validate(models); errs == nil {
+		t.Errorf("Validate accepted mappings without a key")
+	}
+}
+
 func TestDispositionPass(t *testing.T) {
 	policy := &scrubbing.Policy{
 		FieldName: []scrubbing.FieldNameRule{
@@ -181,3 +791,20 @@ func TestDataPreserveJSON(t *testing.T) {
 		t.Errorf("scrubbed JSON does not match original under null policy!")
 	}
 }
+
+func TestDataPathNames(t *testing.T) {
+	policy := &scrubbing.Policy{
+		FieldName: []scrubbing.FieldNameRule{
+			{In: regexp.MustCompile(`^users\.settings\.billing\.email$`), Out: "replace(billing)"},
+			{In: regexp.MustCompile(`^profile\.addresses\[\]\.zip$`), Out: "replace(zip)"},
+			{In: regexp.MustCompile(`^tags\[\]$`), Out: "replace(tag)"},
+		},
+	}
+	in := `{"billing":{"email":"a"},"marketing":{"email":"b"},"profile":{"addresses":[{"zip":"c"}]},"tags":["d","e"],"zip":"f"}`
+	exp := `{"billing":{"email":"billing"},"marketing":{"email":"b"},"profile":{"addresses":[{"zip":"zip"}]},"tags":["tag","tag"],"zip":"f"}`
+
+	scrubber := scrubbing.NewScrubber(salt, false, policy, nil)
+	if got := scrubber.ScrubString(in, []string{"settings", "users.settings", "users.3"}); got != exp {
+		t.Errorf(`scrub(%q) = %q, want %q`, in, got, exp)
+	}
+}
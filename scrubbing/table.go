@@ -0,0 +1,56 @@
+package scrubbing
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TableRule describes what to do with the data of an entire table,
+// irrespective of its fields. The table's schema is always kept.
+type TableRule struct {
+	// Table is the name of the table (matched case-insensitively).
+	Table string `json:"table"`
+	// Out describes what to do with the table's data:
+	//   - "drop": omit all of its rows
+	//   - "replace(file)": omit all of its rows, and output the statements
+	//     in file (e.g. INSERTs of fixture rows) instead
+	Out Disposition `json:"out"`
+}
+
+func (r TableRule) String() string {
+	return fmt.Sprintf("%s ―➤ %s", r.Table, r.Out.String())
+}
+
+// MatchTable returns the disposition of the named table if any table rule
+// applies to it. Otherwise it returns the empty string.
+func (p Policy) MatchTable(name string) Disposition {
+	for _, rule := range p.Tables {
+		if strings.EqualFold(rule.Table, name) {
+			return rule.Out
+		}
+	}
+	return ""
+}
+
+// Checks a table rule; the fixtures of "replace(file)" must be readable.
+func validateTable(rule TableRule) error {
+	if rule.Table == "" {
+		return fmt.Errorf("missing table name")
+	}
+	switch rule.Out.Action() {
+	case "drop":
+		return nil
+	case "replace":
+		params := rule.Out.Parameters()
+		if len(params) != 1 {
+			return fmt.Errorf("replace needs a file of fixtures")
+		}
+		f, err := os.Open(params[0])
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}
+	return fmt.Errorf("unknown table action %q", rule.Out.Action())
+}
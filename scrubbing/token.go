@@ -0,0 +1,175 @@
+package scrubbing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math"
+	"math/big"
+	"unicode/utf8"
+)
+
+// Hash functions that can be used with HMAC, by name.
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha224":     sha256.New224,
+	"sha256":     sha256.New,
+	"sha384":     sha512.New384,
+	"sha512":     sha512.New,
+	"sha512_256": sha512.New512_256,
+}
+
+// Bits of the digest that are held back when encoding tokens with an
+// alphabet, so that the modulo bias of the encoding is negligible.
+const alphabetReserveBits = 64
+
+// Tokenizer describes how to replace values with keyed HMAC tokens for the
+// "tokenize(name)" disposition. Tokens are deterministic, so the same value
+// always yields the same token, but they cannot be computed (or reversed by
+// brute force) without the key.
+type Tokenizer struct {
+	// Key is the name of a key in the policy's Keys.
+	Key string `json:"key"`
+	// Algorithm is the hash function to use with HMAC (default "sha256").
+	Algorithm string `json:"algorithm"`
+	// Encoding is the representation of the digest: "hex" (the default) or
+	// "base32". It is ignored if Alphabet is specified.
+	Encoding string `json:"encoding"`
+	// Alphabet, if not empty, is a set of characters to represent the
+	// digest with (e.g. "0123456789" for numeric tokens).
+	Alphabet string `json:"alphabet"`
+	// Length is the number of characters in each token; 0 means as many as
+	// the digest can provide.
+	Length int `json:"length"`
+}
+
+func (t Tokenizer) algorithm() string {
+	if t.Algorithm == "" {
+		return "sha256"
+	}
+	return t.Algorithm
+}
+
+// Returns the maximum token length that the digest can provide.
+func (t Tokenizer) maxLength() int {
+	bits := hashAlgorithms[t.algorithm()]().Size() * 8
+	switch {
+	case t.Alphabet != "":
+		radix := float64(utf8.RuneCountInString(t.Alphabet))
+		return int(float64(bits-alphabetReserveBits) / math.Log2(radix))
+	case t.Encoding == "base32":
+		return (bits + 4) / 5
+	default:
+		return bits / 4
+	}
+}
+
+// Validate checks that the tokenizer is internally consistent.
+func (t Tokenizer) Validate() error {
+	if hashAlgorithms[t.algorithm()] == nil {
+		return fmt.Errorf("unknown hash algorithm %q", t.Algorithm)
+	}
+	if t.Alphabet != "" {
+		seen := make(map[rune]bool)
+		for _, r := range t.Alphabet {
+			if seen[r] {
+				return fmt.Errorf("alphabet %q has duplicate character %q", t.Alphabet, r)
+			}
+			seen[r] = true
+		}
+		if len(seen) < 2 {
+			return fmt.Errorf("alphabet %q must have at least two characters", t.Alphabet)
+		}
+	} else {
+		switch t.Encoding {
+		case "", "hex", "base32":
+		default:
+			return fmt.Errorf("unknown encoding %q", t.Encoding)
+		}
+	}
+	if t.Length < 0 || t.Length > t.maxLength() {
+		return fmt.Errorf("length must be at most %d", t.maxLength())
+	}
+	return nil
+}
+
+// Token computes the token for a value.
+func (t Tokenizer) Token(key []byte, s string) string {
+	mac := hmac.New(hashAlgorithms[t.algorithm()], key)
+	mac.Write([]byte(s))
+	digest := mac.Sum(nil)
+
+	length := t.Length
+	if length == 0 {
+		length = t.maxLength()
+	}
+
+	if t.Alphabet != "" {
+		alphabet := []rune(t.Alphabet)
+		radix := big.NewInt(int64(len(alphabet)))
+		value := new(big.Int).SetBytes(digest)
+		token := make([]rune, length)
+		d := new(big.Int)
+		for i := length - 1; i >= 0; i-- {
+			value.DivMod(value, radix, d)
+			token[i] = alphabet[d.Int64()]
+		}
+		return string(token)
+	}
+
+	var encoded string
+	switch t.Encoding {
+	case "base32":
+		encoded = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(digest)
+	default:
+		encoded = hex.EncodeToString(digest)
+	}
+	return encoded[:length]
+}
+
+// NumberToken computes a token for the decimal representation of a number,
+// e.g. for a numeric SQL column that cannot hold Token's output. The digits
+// are replaced by digits derived from the HMAC, but the sign and decimal
+// point are kept, so the token is a number of the same size; a multi-digit
+// integer part never begins with zero. Alphabet, Encoding and Length do not
+// apply.
+func (t Tokenizer) NumberToken(key []byte, s string) string {
+	b := []byte(s)
+	positions := make([]int, 0, len(b))
+	for i, c := range b {
+		if c >= '0' && c <= '9' {
+			positions = append(positions, i)
+		}
+	}
+	if len(positions) == 0 {
+		return s
+	}
+
+	mac := hmac.New(hashAlgorithms[t.algorithm()], key)
+	mac.Write(b)
+	digest := mac.Sum(nil)
+	// extend the digest for very long numbers (4 bits > log2(10) per digit)
+	for len(digest)*8 < 4*len(positions)+alphabetReserveBits {
+		mac.Reset()
+		mac.Write(digest)
+		digest = mac.Sum(digest)
+	}
+
+	value := new(big.Int).SetBytes(digest)
+	ten, nine := big.NewInt(10), big.NewInt(9)
+	d := new(big.Int)
+	for j := len(positions) - 1; j >= 0; j-- {
+		i := positions[j]
+		if j == 0 && i+1 < len(b) && b[i+1] >= '0' && b[i+1] <= '9' {
+			value.DivMod(value, nine, d)
+			b[i] = '1' + byte(d.Int64())
+		} else {
+			value.DivMod(value, ten, d)
+			b[i] = '0' + byte(d.Int64())
+		}
+	}
+	return string(b)
+}